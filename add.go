@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Adds a task for every title, all with the notes and due of --notes, or
+// --notes-from-file or else --notes-template, and --due. More than one title
+// is added in parallel, so the tasks may end up in any order, except with
+// --append: then the tasks are added one after the other below the last
+// task, in the order given.
+func add(srv *tasks.Service, tasklistId, tasklistTitle string, titles []string) {
+	if len(titles) == 0 {
+		usageError("Missing task title")
+	}
+	titles = decorateTitles(titles)
+	notes, due := *addNotes, ""
+	if *notesFile != "" {
+		if *addNotes != "" {
+			usageError("Only one of --notes and --notes-from-file can be given")
+		}
+		notes = readNotes(*notesFile)
+	}
+	if !flagGiven("notes") && *notesFile == "" {
+		notes = notesTemplate(tasklistTitle)
+	}
+	if *addDue != "" {
+		var clock string
+		var err error
+		due, clock, err = parseDueTime(*addDue)
+		if err != nil {
+			fatalf("%v", err)
+		}
+		notes = withDueTime(notes, clock)
+	}
+	var items []*tasks.Task
+	if *ifAbsent || *parentTitle != "" || *appendTasks {
+		items = fetchTasks(srv, tasklistId)
+	}
+	parent := *parentId
+	if *parentTitle != "" {
+		parent = parentFromFlags(items)
+	}
+	if *ifAbsent {
+		// The titles to add are compared with each other as well, so that a
+		// title given twice is only added once.
+		var absent []string
+		var seen []*tasks.Task
+		for _, title := range titles {
+			if existing := findExisting(items, title); existing != nil {
+				fmt.Printf("Task %q already exists: %s\n", existing.Title, existing.Id)
+				continue
+			}
+			if findExisting(seen, title) != nil {
+				fmt.Printf("Task %q is given more than once, adding it once\n", title)
+				continue
+			}
+			seen = append(seen, &tasks.Task{Title: title})
+			absent = append(absent, title)
+		}
+		titles = absent
+	}
+
+	previous := ""
+	if s := siblings(items, parent, ""); *appendTasks && len(s) > 0 {
+		previous = s[len(s)-1].Id
+	}
+	insert := func(title string) error {
+		call := srv.Tasks.Insert(tasklistId, &tasks.Task{
+			Title: title,
+			Notes: notes,
+			Due:   due,
+		})
+		if parent != "" {
+			call = call.Parent(parent)
+		}
+		if previous != "" {
+			call = call.Previous(previous)
+		}
+		inserted, err := call.Do()
+		if err == nil && *appendTasks {
+			previous = inserted.Id
+		}
+		return err
+	}
+	switch {
+	case len(titles) == 0:
+	case len(titles) == 1:
+		if err := insert(titles[0]); err != nil {
+			fatalf("Could not add task: %v", err)
+		}
+	case *appendTasks:
+		summarize("add task", forEachTask(titles, 1, insert))
+	default:
+		summarize("add task", forEachTask(titles, *concurrency, insert))
+	}
+}
+
+// Numbers the titles with --number, counting from --number-start, and puts
+// --prefix in front of them, as in "Step 1. Title".
+func decorateTitles(titles []string) []string {
+	if !*number && *prefix == "" {
+		return titles
+	}
+	decorated := make([]string, len(titles))
+	for i, title := range titles {
+		if *number {
+			title = fmt.Sprintf("%d. %s", *numberStart+i, title)
+		}
+		decorated[i] = *prefix + title
+	}
+	return decorated
+}
+
+// Reads notes from a file. Trailing newlines, which editors usually end a
+// file with, are dropped.
+func readNotes(file string) string {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		fatalf("Could not read notes: %v", err)
+	}
+	return strings.TrimRight(string(b), "\r\n")
+}
+
+// Returns the notes of --notes-template with its placeholders filled in:
+// {{date}} becomes today's date and {{list}} the title of the tasklist.
+// Unless the flag is given, a template for the tasklist in
+// notes_templates.json takes precedence over GTASKS_NOTES_TEMPLATE.
+func notesTemplate(tasklistTitle string) string {
+	tmpl := *notesTmpl
+	if t, ok := readNotesTemplates()[tasklistTitle]; ok && !flagGiven("notes-template") {
+		tmpl = t
+	}
+	if tmpl == "" {
+		return ""
+	}
+	return strings.NewReplacer(
+		"{{date}}", currentTime().In(location()).Format(dateLayout()),
+		"{{list}}", tasklistTitle,
+	).Replace(tmpl)
+}
+
+// The file notes_templates.json maps tasklist titles to the notes template
+// of tasks added to them.
+func notesTemplatesFile() string {
+	return filepath.Join(getConfigDir(), "notes_templates.json")
+}
+
+func readNotesTemplates() map[string]string {
+	templates := make(map[string]string)
+	b, err := os.ReadFile(notesTemplatesFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return templates
+	}
+	if err != nil {
+		fatalf("Could not read notes templates: %v", err)
+	}
+	if err := json.Unmarshal(b, &templates); err != nil {
+		fatalf("Could not parse %s: %v", notesTemplatesFile(), err)
+	}
+	return templates
+}
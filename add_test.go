@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Adds titles given twice, in different case, and one that exists already,
+// with --if-absent, which has to add every missing title exactly once.
+func TestAddIfAbsent(t *testing.T) {
+	api, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"list": {{Id: "a", Title: "Bread", Status: "needsAction"}},
+	})
+	old := *ifAbsent
+	*ifAbsent = true
+	t.Cleanup(func() { *ifAbsent = old })
+
+	add(srv, "list", "Groceries", []string{"Milk", "bread", " milk", "Eggs", "Milk"})
+
+	count := make(map[string]int)
+	for _, fields := range api.lists["list"] {
+		var title string
+		json.Unmarshal(fields["title"], &title)
+		count[title]++
+	}
+	if len(api.lists["list"]) != 3 || count["Bread"] != 1 || count["Milk"] != 1 || count["Eggs"] != 1 {
+		t.Errorf("tasks by title are %v, want Bread, Milk and Eggs once", count)
+	}
+}
+
+// Takes the notes template of a list from notes_templates.json over the one
+// for every list, unless --notes-template is given.
+func TestNotesTemplatePerList(t *testing.T) {
+	pinNow(t, "2024-06-05T10:00:00Z")
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	os.MkdirAll(filepath.Join(dir, "gtasks"), 0o700)
+	if err := os.WriteFile(filepath.Join(dir, "gtasks", "notes_templates.json"), []byte(`{"Work": "for {{list}}"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	old := *notesTmpl
+	*notesTmpl = "added {{date}}"
+	t.Cleanup(func() { *notesTmpl = old; delete(given, "notes-template") })
+
+	if got := notesTemplate("Work"); got != "for Work" {
+		t.Errorf("template of Work is %q, want %q", got, "for Work")
+	}
+	if got := notesTemplate("Home"); got != "added 2024-06-05" {
+		t.Errorf("template of Home is %q, want %q", got, "added 2024-06-05")
+	}
+	given["notes-template"] = true
+	if got := notesTemplate("Work"); got != "added 2024-06-05" {
+		t.Errorf("template of Work with --notes-template is %q, want %q", got, "added 2024-06-05")
+	}
+}
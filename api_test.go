@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/tasks/v1"
+)
+
+// fakeAPI is an in-memory stand-in for the parts of the Tasks API the tests
+// use. Tasks are kept as the JSON objects the API would return, so that
+// patches behave like the API's: fields given are set, null ones removed.
+type fakeAPI struct {
+	mu    sync.Mutex
+	lists map[string][]map[string]json.RawMessage
+	// patches holds the bodies of the PATCH requests received.
+	patches []map[string]json.RawMessage
+	// deletes counts the DELETE requests that succeeded.
+	deletes int
+}
+
+// Starts a fake API with the given tasks per tasklist id and returns a
+// service talking to it.
+func newFakeAPI(t *testing.T, lists map[string][]*tasks.Task) (*fakeAPI, *tasks.Service) {
+	t.Helper()
+	api := &fakeAPI{lists: make(map[string][]map[string]json.RawMessage)}
+	for list, items := range lists {
+		for _, task := range items {
+			b, _ := json.Marshal(task)
+			var fields map[string]json.RawMessage
+			json.Unmarshal(b, &fields)
+			api.lists[list] = append(api.lists[list], fields)
+		}
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /tasks/v1/lists/{list}/tasks", api.listTasks)
+	mux.HandleFunc("POST /tasks/v1/lists/{list}/tasks", api.insertTask)
+	mux.HandleFunc("GET /tasks/v1/lists/{list}/tasks/{task}", api.getTask)
+	mux.HandleFunc("PUT /tasks/v1/lists/{list}/tasks/{task}", api.changeTask)
+	mux.HandleFunc("PATCH /tasks/v1/lists/{list}/tasks/{task}", api.changeTask)
+	mux.HandleFunc("DELETE /tasks/v1/lists/{list}/tasks/{task}", api.deleteTask)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	srv, err := tasks.NewService(context.Background(), option.WithEndpoint(server.URL+"/"), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api, srv
+}
+
+func (api *fakeAPI) find(r *http.Request) map[string]json.RawMessage {
+	for _, fields := range api.lists[r.PathValue("list")] {
+		if string(fields["id"]) == `"`+r.PathValue("task")+`"` {
+			return fields
+		}
+	}
+	return nil
+}
+
+func (api *fakeAPI) listTasks(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{"items": api.lists[r.PathValue("list")]})
+}
+
+// Adds a task at the end of the list, with an id made up from the number of
+// tasks in it, below the parent given.
+func (api *fakeAPI) insertTask(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list := r.PathValue("list")
+	fields["id"], _ = json.Marshal(fmt.Sprintf("new%d", len(api.lists[list])))
+	fields["status"] = json.RawMessage(`"needsAction"`)
+	if parent := r.URL.Query().Get("parent"); parent != "" {
+		fields["parent"], _ = json.Marshal(parent)
+	}
+	api.lists[list] = append(api.lists[list], fields)
+	json.NewEncoder(w).Encode(fields)
+}
+
+func (api *fakeAPI) getTask(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	fields := api.find(r)
+	if fields == nil {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(fields)
+}
+
+// Applies an update or patch. Like the API, completing a task sets its
+// completion time if none is given.
+func (api *fakeAPI) changeTask(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	fields := api.find(r)
+	if fields == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodPatch {
+		api.patches = append(api.patches, body)
+	}
+	for name, value := range body {
+		if string(value) == "null" {
+			delete(fields, name)
+		} else {
+			fields[name] = value
+		}
+	}
+	if _, ok := fields["completed"]; !ok && string(fields["status"]) == `"completed"` {
+		fields["completed"] = json.RawMessage(`"2024-06-05T10:00:00.000Z"`)
+	}
+	json.NewEncoder(w).Encode(fields)
+}
+
+// Deletes a task along with its subtasks, like the API does. Deleting a task
+// that is gone already fails.
+func (api *fakeAPI) deleteTask(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.find(r) == nil {
+		http.NotFound(w, r)
+		return
+	}
+	list := r.PathValue("list")
+	deleted := map[string]bool{`"` + r.PathValue("task") + `"`: true}
+	for changed := true; changed; {
+		changed = false
+		for _, fields := range api.lists[list] {
+			if id := string(fields["id"]); !deleted[id] && deleted[string(fields["parent"])] {
+				deleted[id], changed = true, true
+			}
+		}
+	}
+	var kept []map[string]json.RawMessage
+	for _, fields := range api.lists[list] {
+		if !deleted[string(fields["id"])] {
+			kept = append(kept, fields)
+		}
+	}
+	api.lists[list] = kept
+	api.deletes++
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Exports the completed tasks of the given tasklists to file, then deletes
+// them. Files ending in .md get markdown, anything else JSON in the backup
+// format. An existing archive file is added to rather than replaced, and
+// nothing is deleted unless writing it succeeded. Completed tasks with
+// pending subtasks are kept, as deleting them would delete the subtasks.
+func archive(srv *tasks.Service, tasklists []*tasks.TaskList, file string) {
+	if file == "" {
+		fatalf("Missing archive file")
+	}
+	var lists []backupList
+	var taskIds []string
+	tasklistIds := make(map[string]string)
+	byId := make(map[string]*tasks.Task)
+	kept := 0
+	for _, tasklist := range tasklists {
+		items := fetchTasks(srv, tasklist.Id)
+		maps.Copy(byId, tasksById(items))
+		keep := pendingParents(items)
+		bl := backupList{Id: tasklist.Id, Title: tasklist.Title, Updated: tasklist.Updated}
+		for _, task := range items {
+			if task.Status == "completed" && keep[task.Id] {
+				kept++
+				continue
+			}
+			if task.Status == "completed" {
+				bl.Tasks = append(bl.Tasks, task)
+				taskIds = append(taskIds, task.Id)
+				tasklistIds[task.Id] = tasklist.Id
+			}
+		}
+		if len(bl.Tasks) > 0 {
+			lists = append(lists, bl)
+		}
+	}
+	if kept > 0 {
+		fmt.Fprintf(os.Stderr, "Keeping %d completed tasks with pending subtasks\n", kept)
+	}
+	if len(taskIds) == 0 {
+		fmt.Println("Nothing to archive")
+		return
+	}
+	if *dryRun {
+		for _, bl := range lists {
+			fmt.Printf("Would archive %d tasks from %s\n", len(bl.Tasks), bl.Title)
+		}
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("Archive and delete %d completed tasks?", len(taskIds)), len(taskIds)) {
+		return
+	}
+
+	if filepath.Ext(file) == ".md" {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			fatalf("Could not open archive: %v", err)
+		}
+		_, err = f.WriteString(markdown(lists))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fatalf("Could not write archive: %v", err)
+		}
+	} else {
+		bf := backupFile{}
+		if b, err := os.ReadFile(file); err == nil {
+			if err := json.Unmarshal(b, &bf); err != nil {
+				fatalf("Could not read existing archive: %v", err)
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			fatalf("Could not read existing archive: %v", err)
+		}
+		bf.Created = time.Now().UTC().Format(time.RFC3339)
+		bf.Tasklists = append(bf.Tasklists, lists...)
+		if err := writeJSONFile(file, bf); err != nil {
+			fatalf("Could not write archive: %v", err)
+		}
+	}
+
+	// Completed subtasks of completed tasks go along with their parent.
+	roots := deletionRoots(byId, taskIds)
+	var rootIds []string
+	for _, taskId := range taskIds {
+		if roots[taskId] == taskId {
+			rootIds = append(rootIds, taskId)
+		}
+	}
+	results := forEachTask(rootIds, *concurrency, func(taskId string) error {
+		return srv.Tasks.Delete(tasklistIds[taskId], taskId).Do()
+	})
+	deleted := make(map[string]bool)
+	for _, r := range results {
+		deleted[r.taskId] = r.err == nil
+	}
+	count := 0
+	for _, taskId := range taskIds {
+		if deleted[roots[taskId]] {
+			count++
+		}
+	}
+	fmt.Printf("Archived %d tasks to %s\n", count, file)
+	summarize("delete", results)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Archives a completed task with completed subtasks, which have to be
+// deleted along with their parent rather than on their own, and one with a
+// pending subtask, which has to stay.
+func TestArchiveSubtasks(t *testing.T) {
+	api, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"list": {
+			{Id: "a", Title: "Trip", Status: "completed"},
+			{Id: "b", Title: "Pack", Parent: "a", Status: "completed"},
+			{Id: "c", Title: "Charger", Parent: "b", Status: "completed"},
+			{Id: "d", Title: "Move", Status: "completed"},
+			{Id: "e", Title: "Boxes", Parent: "d", Status: "needsAction"},
+		},
+	})
+	file := filepath.Join(t.TempDir(), "archive.json")
+
+	archive(srv, []*tasks.TaskList{{Id: "list", Title: "Home"}}, file)
+
+	if api.deletes != 1 {
+		t.Errorf("%d deletes, want 1 for the task with its subtasks", api.deletes)
+	}
+	var left []string
+	for _, fields := range api.lists["list"] {
+		var id string
+		json.Unmarshal(fields["id"], &id)
+		left = append(left, id)
+	}
+	if len(left) != 2 || left[0] != "d" || left[1] != "e" {
+		t.Errorf("tasks left are %q, want d and e", left)
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bf backupFile
+	if err := json.Unmarshal(b, &bf); err != nil {
+		t.Fatal(err)
+	}
+	if len(bf.Tasklists) != 1 || len(bf.Tasklists[0].Tasks) != 3 {
+		t.Errorf("archive has %v, want the 3 tasks of one list", bf.Tasklists)
+	}
+}
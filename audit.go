@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// auditEntry is a line of the --audit-log file, written for every request
+// that changes tasks or tasklists. Path, Query and Body are what replay needs
+// to make the request again, Created is the id of a task or tasklist the
+// request created.
+type auditEntry struct {
+	Time    string          `json:"time"`
+	Command string          `json:"command"`
+	Method  string          `json:"method"`
+	List    string          `json:"list,omitempty"`
+	Task    string          `json:"task,omitempty"`
+	Status  int             `json:"status,omitempty"`
+	Error   string          `json:"error,omitempty"`
+	Path    string          `json:"path,omitempty"`
+	Query   string          `json:"query,omitempty"`
+	Body    json.RawMessage `json:"body,omitempty"`
+	Created string          `json:"created,omitempty"`
+}
+
+// auditTransport appends an entry to the audit log for every request but
+// reads. Each entry is a single write to a file opened for appending, so
+// lines of processes sharing the log don't get interleaved.
+type auditTransport struct {
+	next http.RoundTripper
+	mu   sync.Mutex
+	file *os.File
+}
+
+func newAuditTransport(next http.RoundTripper, file string) *auditTransport {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		fatalf("Could not open audit log: %v", err)
+	}
+	return &auditTransport{next: next, file: f}
+}
+
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || req.URL.Host == "oauth2.googleapis.com" {
+		return t.next.RoundTrip(req)
+	}
+	entry := auditEntry{
+		Time:    time.Now().UTC().Format(time.RFC3339),
+		Command: arg(0),
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Query:   auditQuery(req.URL.Query()),
+	}
+	entry.List, entry.Task = apiTarget(req.URL.Path)
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			b, _ := io.ReadAll(body)
+			body.Close()
+			if json.Valid(b) {
+				entry.Body = b
+			}
+		}
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		entry.Error = err.Error()
+		t.write(entry)
+		return resp, err
+	}
+	entry.Status = resp.StatusCode
+	if req.Method == http.MethodPost && resp.StatusCode == http.StatusOK && isInsert(req.URL.Path) {
+		// The body is read to get the id of what was created and then put
+		// back for the caller.
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		if err != nil {
+			t.write(entry)
+			return nil, err
+		}
+		var created struct{ Id string }
+		if json.Unmarshal(b, &created) == nil {
+			entry.Created = created.Id
+		}
+	}
+	t.write(entry)
+	return resp, nil
+}
+
+// Returns the query parameters of a request without the ones every request
+// has, like alt=json, as they don't tell what the request changed.
+func auditQuery(query url.Values) string {
+	query.Del("alt")
+	query.Del("prettyPrint")
+	return query.Encode()
+}
+
+// Reports whether the path is that of inserting a task or a tasklist.
+func isInsert(path string) bool {
+	return strings.HasSuffix(path, "/tasks") || strings.HasSuffix(path, "/users/@me/lists")
+}
+
+func (t *auditTransport) write(entry auditEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.file.Write(append(b, '\n')); err != nil {
+		logger.Warn("could not write audit log", "error", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+const maxAttempts = 5
+
+// Calls f until it succeeds, retrying with exponential backoff as long as the
+// API reports a rate limit or a server side error.
+func withBackoff(f func() error) error {
+	delay := time.Second
+	for attempt := 1; ; attempt++ {
+		err := f()
+		if err == nil || attempt == maxAttempts || !isRetryable(err) {
+			return err
+		}
+		select {
+		case <-time.After(delay):
+		case <-interrupted.Done():
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// finalError marks an error that withBackoff doesn't retry, even if the
+// error it wraps would be, e.g. because the call it belongs to had an effect
+// that repeating it would duplicate.
+type finalError struct {
+	error
+}
+
+func (e finalError) Unwrap() error {
+	return e.error
+}
+
+func isRetryable(err error) bool {
+	if errors.As(err, new(finalError)) {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.Code == http.StatusTooManyRequests || apiErr.Code >= 500 {
+		return true
+	}
+	for _, item := range apiErr.Errors {
+		if item.Reason == "rateLimitExceeded" || item.Reason == "userRateLimitExceeded" {
+			return true
+		}
+	}
+	return false
+}
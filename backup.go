@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// backupFile is the on-disk format written by the backup command. A delta
+// backup has Since set and only contains tasks updated after that time. A
+// backup that failed or was interrupted part way has Partial set.
+type backupFile struct {
+	Created   string       `json:"created"`
+	Since     string       `json:"since,omitempty"`
+	Partial   bool         `json:"partial,omitempty"`
+	Tasklists []backupList `json:"tasklists"`
+}
+
+type backupList struct {
+	Id      string        `json:"id"`
+	Title   string        `json:"title"`
+	Updated string        `json:"updated"`
+	Tasks   []*tasks.Task `json:"tasks"`
+}
+
+// backupManifest is written next to the files of a split backup and maps
+// every tasklist to the file it was written to.
+type backupManifest struct {
+	Created   string          `json:"created"`
+	Since     string          `json:"since,omitempty"`
+	Tasklists []manifestEntry `json:"tasklists"`
+}
+
+type manifestEntry struct {
+	Id    string `json:"id"`
+	Title string `json:"title"`
+	File  string `json:"file"`
+}
+
+// The file last_backup records when the last backup of all tasklists was
+// started, so that "--since last" can pick up where it left off.
+func lastBackupFile() string {
+	return filepath.Join(getConfigDir(), "last_backup")
+}
+
+// Writes all tasks of all tasklists to a file. With --since, only tasks
+// updated after that time are included, deleted ones as well. With --split,
+// every tasklist is written to its own file in --output-dir instead.
+func backup(srv *tasks.Service, tasklists []*tasks.TaskList, file string) {
+	updatedMin := *since
+	if file == "" && !*split {
+		fatalf("Missing backup file")
+	}
+	started := time.Now().UTC().Format(time.RFC3339)
+	if updatedMin == "last" {
+		b, err := os.ReadFile(lastBackupFile())
+		if err != nil {
+			fatalf("Could not read time of last backup, run a full backup first: %v", err)
+		}
+		updatedMin = strings.TrimSpace(string(b))
+	}
+	if updatedMin != "" {
+		if _, err := time.Parse(time.RFC3339, updatedMin); err != nil {
+			fatalf("Invalid since timestamp: %v", err)
+		}
+	}
+
+	// Lists are fetched in parallel. Without --split, the file is rewritten
+	// with the lists done so far after each one, so that an interrupted
+	// backup still leaves a valid, if partial, file behind.
+	bf := backupFile{Created: started, Since: updatedMin, Partial: true}
+	done := make([]*backupList, len(tasklists))
+	byId := make(map[string]int)
+	var ids []string
+	for i, tasklist := range tasklists {
+		byId[tasklist.Id] = i
+		ids = append(ids, tasklist.Id)
+	}
+	var mu sync.Mutex
+	count := 0
+	var writeErr error
+	results := forEachTask(ids, *concurrency, func(tasklistId string) error {
+		call := srv.Tasks.List(tasklistId).ShowCompleted(true).ShowHidden(true)
+		if updatedMin != "" {
+			call = call.UpdatedMin(updatedMin).ShowDeleted(true)
+		}
+		items, err := allTasks(call)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		tasklist := tasklists[byId[tasklistId]]
+		done[byId[tasklistId]] = &backupList{
+			Id:      tasklist.Id,
+			Title:   tasklist.Title,
+			Updated: tasklist.Updated,
+			Tasks:   items,
+		}
+		count++
+		if !*quiet {
+			fmt.Fprintf(os.Stderr, "Backed up %d/%d lists\n", count, len(tasklists))
+		}
+		if !*split && writeErr == nil {
+			bf.Tasklists = collectLists(done, updatedMin)
+			writeErr = writeJSONFile(file, bf)
+		}
+		return nil
+	})
+	if writeErr != nil {
+		fatalf("Could not write backup: %v", writeErr)
+	}
+
+	failed := slices.ContainsFunc(results, func(r result) bool { return r.err != nil })
+	bf.Partial = failed
+	bf.Tasklists = collectLists(done, updatedMin)
+	if *split {
+		writeSplitBackup(bf, *outputDir)
+	} else {
+		writeJSON(file, bf)
+	}
+	if failed {
+		fmt.Fprintf(os.Stderr, "Backup is partial, %d of %d lists were written\n", count, len(tasklists))
+		summarize("back up tasklist", results)
+		exit(exitFailure)
+	}
+	// A backup of only some lists leaves out changes to the others, which the
+	// next "--since last" would then skip.
+	if *listFilter != "" {
+		return
+	}
+	if err := os.WriteFile(lastBackupFile(), []byte(started+"\n"), 0600); err != nil {
+		fatalf("Could not record time of backup: %v", err)
+	}
+}
+
+// Returns the lists backed up so far in their original order. Deltas leave
+// out the lists without changes.
+func collectLists(done []*backupList, updatedMin string) []backupList {
+	var lists []backupList
+	for _, list := range done {
+		if list == nil || updatedMin != "" && len(list.Tasks) == 0 {
+			continue
+		}
+		lists = append(lists, *list)
+	}
+	return lists
+}
+
+// Writes every tasklist of a backup to its own file, named after the list
+// title, along with a manifest.json.
+func writeSplitBackup(bf backupFile, dir string) {
+	if dir == "" {
+		dir = "."
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fatalf("Could not create output directory: %v", err)
+	}
+	manifest := backupManifest{Created: bf.Created, Since: bf.Since}
+	used := map[string]bool{"manifest.json": true}
+	for _, list := range bf.Tasklists {
+		name := sanitizeFilename(list.Title)
+		file := name + ".json"
+		for n := 2; used[strings.ToLower(file)]; n++ {
+			file = fmt.Sprintf("%s-%d.json", name, n)
+		}
+		used[strings.ToLower(file)] = true
+		writeJSON(filepath.Join(dir, file), backupFile{
+			Created:   bf.Created,
+			Since:     bf.Since,
+			Tasklists: []backupList{list},
+		})
+		manifest.Tasklists = append(manifest.Tasklists, manifestEntry{
+			Id:    list.Id,
+			Title: list.Title,
+			File:  file,
+		})
+	}
+	writeJSON(filepath.Join(dir, "manifest.json"), manifest)
+}
+
+func writeJSON(file string, v any) {
+	if err := writeJSONFile(file, v); err != nil {
+		fatalf("Could not write backup: %v", err)
+	}
+}
+
+// Writes v as JSON to a temporary file first and then renames it, so that
+// file is never left half written.
+func writeJSONFile(file string, v any) error {
+	bs, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, bs, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
+// Turns a tasklist title into a safe file name without extension. Anything
+// but letters, digits, dashes and underscores is replaced, so the name can't
+// contain path separators or start with a dot.
+func sanitizeFilename(title string) string {
+	var b strings.Builder
+	for _, r := range title {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	name := strings.Trim(b.String(), "_")
+	if len([]rune(name)) > 100 {
+		name = string([]rune(name)[:100])
+	}
+	if name == "" {
+		return "tasklist"
+	}
+	return name
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// inBatch is set while the lines of a batch run. fatalf and exit then only
+// end the current line by panicking with a batchFailure.
+var inBatch bool
+
+type batchFailure struct {
+	msg  string
+	code int
+}
+
+// Runs the commands in file, or stdin for "-", one per line, with the same
+// service and tasklists. Empty lines and lines starting with # are skipped.
+// The flags given to batch itself apply to every line, in addition to the
+// flags on the line. Failed lines are reported and don't stop the others.
+func batch(srv *tasks.Service, tasklists []*tasks.TaskList, granted, file string) {
+	var r io.Reader = os.Stdin
+	if file == "" {
+		fatalf("Missing batch file")
+	}
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			fatalf("Could not open batch file: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	defaults := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		defaults[f.Name] = f.Value.String()
+	})
+	inBatch = true
+	batchGiven := maps.Clone(given)
+
+	succeeded, failed := 0, 0
+	scanner := bufio.NewScanner(r)
+	for n := 1; interrupted.Err() == nil && scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for name, value := range defaults {
+			flag.Lookup(name).Value.Set(value)
+		}
+		given = maps.Clone(batchGiven)
+		if err := runBatchLine(srv, tasklists, granted, line); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "Line %d failed: %v\n", n, err)
+		} else {
+			succeeded++
+		}
+	}
+	inBatch = false
+	if err := scanner.Err(); err != nil {
+		fatalf("Could not read batch file: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d lines succeeded\n", succeeded, succeeded+failed)
+	if failed > 0 {
+		exit(exitFailure)
+	}
+}
+
+func runBatchLine(srv *tasks.Service, tasklists []*tasks.TaskList, granted, line string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(batchFailure)
+			if !ok {
+				panic(r)
+			}
+			switch {
+			case failure.code == 0:
+				err = nil
+			case failure.msg != "":
+				err = errors.New(failure.msg)
+			default:
+				err = fmt.Errorf("exit status %d", failure.code)
+			}
+		}
+	}()
+	words, err := splitLine(line)
+	if err != nil {
+		return err
+	}
+	if err := parseArgs(words); err != nil {
+		return err
+	}
+	if arg(0) == "" {
+		return errors.New("Missing command")
+	}
+	runCommand(srv, tasklists, granted)
+	return nil
+}
+
+// Splits a line into words at whitespace, like a shell does. Single and
+// double quotes group words, a backslash escapes the next character outside
+// of single quotes.
+func splitLine(line string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	escaped := false
+	for _, c := range line {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("Unterminated quote or escape")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
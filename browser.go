@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// Opens url in the default browser without waiting for it. Errors are
+// returned for the caller to ignore, the URL is always printed as well.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Prints a grid of the current month, or week with --week, with the number
+// of pending tasks due on each day. Today is marked with *, past days that
+// still have pending tasks with !.
+func calendar(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	counts := make(map[string]int)
+	for _, tasklist := range tasklists {
+		items, err := allTasks(srv.Tasks.List(tasklist.Id).ShowCompleted(false))
+		if err != nil {
+			fatalf("Could not list tasklist items: %v", err)
+		}
+		for _, task := range items {
+			if task.Due != "" && task.Status != "completed" {
+				counts[task.Due[:10]]++
+			}
+		}
+	}
+
+	printCalendar(counts, currentTime().In(location()))
+}
+
+// Prints the calendar grid for the month or week containing now, given the
+// number of tasks due per date.
+func printCalendar(counts map[string]int, now time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	// Weeks start on Monday.
+	weekday := (int(today.Weekday()) + 6) % 7
+	var first, last time.Time
+	if *week {
+		first = today.AddDate(0, 0, -weekday)
+		last = first.AddDate(0, 0, 6)
+		fmt.Printf("Week of %s\n", first.Format(dateLayout()))
+	} else {
+		first = today.AddDate(0, 0, 1-today.Day())
+		last = first.AddDate(0, 1, -1)
+		fmt.Printf("%s %d\n", today.Month(), today.Year())
+	}
+
+	fmt.Println("Mo    Tu    We    Th    Fr    Sa    Su")
+	var sb strings.Builder
+	sb.WriteString(strings.Repeat("      ", (int(first.Weekday())+6)%7))
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		n := counts[day.Format(time.DateOnly)]
+		mark := " "
+		if day.Equal(today) {
+			mark = "*"
+		} else if day.Before(today) && n > 0 {
+			mark = "!"
+		}
+		count := ""
+		if n > 0 {
+			count = fmt.Sprint(n)
+		}
+		fmt.Fprintf(&sb, "%2d%s%-2s", day.Day(), mark, count)
+		if day.Weekday() == time.Sunday {
+			fmt.Println(strings.TrimRight(sb.String(), " "))
+			sb.Reset()
+		} else {
+			sb.WriteString(" ")
+		}
+	}
+	if sb.Len() > 0 {
+		fmt.Println(strings.TrimRight(sb.String(), " "))
+	}
+
+	overdue := 0
+	for date, n := range counts {
+		if date < first.Format(time.DateOnly) {
+			overdue += n
+		}
+	}
+	if overdue > 0 {
+		fmt.Printf("%d overdue from before\n", overdue)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Marks every pending task of a tasklist as completed, after confirmation.
+// With --uncheck-all it goes the other way and marks every completed task,
+// except the hidden ones, as pending again.
+func checkAll(srv *tasks.Service, tasklistId string) {
+	from, to := "needsAction", "completed"
+	if *uncheckAll {
+		from, to = "completed", "needsAction"
+	}
+	var taskIds []string
+	for _, task := range fetchTasks(srv, tasklistId) {
+		if task.Status == from && !task.Hidden {
+			taskIds = append(taskIds, task.Id)
+		}
+	}
+	if len(taskIds) == 0 {
+		fmt.Println("Nothing to change")
+		return
+	}
+	verb := "check"
+	if *uncheckAll {
+		verb = "uncheck"
+	}
+	if !confirmBulk(fmt.Sprintf("Mark %d tasks as %s?", len(taskIds), to), len(taskIds)) {
+		return
+	}
+	patch := &tasks.Task{Status: to}
+	if to == "needsAction" {
+		patch = reopenPatch()
+	}
+	results := forEachTask(taskIds, *concurrency, func(taskId string) error {
+		_, err := srv.Tasks.Patch(tasklistId, taskId, patch).Do()
+		return err
+	})
+	summarize(verb, results)
+}
+
+// Returns the patch marking a task as pending again. Only changing the
+// status would leave the completion time set, so it is cleared explicitly,
+// and so is hidden, so the task shows up in the app again.
+func reopenPatch() *tasks.Task {
+	return &tasks.Task{
+		Status:          "needsAction",
+		Hidden:          false,
+		NullFields:      []string{"Completed"},
+		ForceSendFields: []string{"Hidden"},
+	}
+}
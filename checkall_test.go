@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Checks a task the way the check command does, hides it like clearing the
+// list does, and then reopens it with reopenPatch, which has to leave no
+// trace of it having been completed.
+func TestCheckThenUncheck(t *testing.T) {
+	api, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"list": {{Id: "a", Title: "Task", Status: "needsAction"}},
+	})
+
+	task, err := srv.Tasks.Get("list", "a").Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	task.Status = "completed"
+	if _, err := srv.Tasks.Update("list", "a", task).Do(); err != nil {
+		t.Fatal(err)
+	}
+	checked, err := srv.Tasks.Patch("list", "a", &tasks.Task{Hidden: true}).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked.Status != "completed" || checked.Completed == nil || !checked.Hidden {
+		t.Fatalf("checked task is %q, completed %v, hidden %v", checked.Status, checked.Completed, checked.Hidden)
+	}
+
+	reopened, err := srv.Tasks.Patch("list", "a", reopenPatch()).Do()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Status != "needsAction" {
+		t.Errorf("status is %q, want needsAction", reopened.Status)
+	}
+	if reopened.Completed != nil {
+		t.Errorf("completion time is %q, want none", *reopened.Completed)
+	}
+	if reopened.Hidden {
+		t.Errorf("task is still hidden")
+	}
+
+	patch := api.patches[len(api.patches)-1]
+	if string(patch["completed"]) != "null" {
+		t.Errorf("patch sets completed to %s, want null", patch["completed"])
+	}
+	if string(patch["hidden"]) != "false" {
+		t.Errorf("patch sets hidden to %s, want false", patch["hidden"])
+	}
+}
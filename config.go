@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+)
+
+// Prints the settings in effect and where each comes from: a flag, an
+// environment variable, a file or the default. The token itself is never
+// printed, only where it is read from and what access it grants.
+func showConfig() {
+	set := given
+	rows := [][]string{{"SETTING", "VALUE", "SOURCE"}}
+	add := func(name, value, source string) {
+		rows = append(rows, []string{name, value, source})
+	}
+	fromFlag := func(names ...string) {
+		for _, name := range names {
+			source := "default"
+			switch {
+			case set[name]:
+				source = "flag"
+			case slices.Contains(envFlags, name) && os.Getenv(flagEnv(name)) != "":
+				source = "env " + flagEnv(name)
+			}
+			add(name, flag.Lookup(name).Value.String(), source)
+		}
+	}
+
+	dir := getConfigDir()
+	add("config-dir", dir, configDirSource())
+
+	credentialsFile := filepath.Join(dir, "credentials.json")
+	switch {
+	case fileExists(credentialsFile):
+		add("credentials", credentialsFile, "file")
+	case os.Getenv("GTASKS_CREDENTIALS") != "":
+		add("credentials", "GTASKS_CREDENTIALS", "env")
+	default:
+		add("credentials", credentialsFile, "missing")
+	}
+
+	tokenFile := filepath.Join(dir, "token.json")
+	scope := ""
+	switch {
+	case os.Getenv("GTASKS_TOKEN") != "":
+		add("token", "GTASKS_TOKEN", "env")
+		tok := &storedToken{}
+		if json.Unmarshal([]byte(os.Getenv("GTASKS_TOKEN")), tok) == nil {
+			scope = tok.Scope
+		}
+	case fileExists(tokenFile):
+		add("token", tokenFile, "file")
+		unlock := lockFile(tokenFile)
+		if tok, err := tokenFromFile(tokenFile); err == nil {
+			scope = tok.Scope
+		}
+		unlock()
+	default:
+		add("token", tokenFile, "missing")
+	}
+	if scope != "" {
+		access := "read and write"
+		if isReadOnly(scope) {
+			access = "read only"
+		}
+		add("access", access, "token")
+	}
+
+	fromFlag("append", "auth-port", "list-cache-ttl", "notes-template")
+	switch {
+	case set["user-agent"]:
+		add("user-agent", *userAgent, "flag")
+	case os.Getenv("GTASKS_USER_AGENT") != "":
+		add("user-agent", *userAgent, "env GTASKS_USER_AGENT")
+	default:
+		add("user-agent", userAgentHeader(), "default")
+	}
+	switch {
+	case set["require-account"]:
+		add("require-account", *requireAcct, "flag")
+	case os.Getenv("GTASKS_REQUIRE_ACCOUNT") != "":
+		add("require-account", os.Getenv("GTASKS_REQUIRE_ACCOUNT"), "env GTASKS_REQUIRE_ACCOUNT")
+	default:
+		add("require-account", "any", "default")
+	}
+	for _, name := range []string{"pre-hook", "post-hook"} {
+		env := flagEnv(name)
+		switch {
+		case set[name]:
+			add(name, hookCommand(name), "flag")
+		case os.Getenv(env) != "":
+			add(name, os.Getenv(env), "env "+env)
+		default:
+			add(name, "none", "default")
+		}
+	}
+	fromFlag("format", "date-format", "time-format", "utc", "notes-width", "page-size", "concurrency", "max-inflight", "confirm-count")
+	switch {
+	case set["proxy"]:
+		add("proxy", *proxy, "flag")
+	case os.Getenv("HTTPS_PROXY") != "":
+		add("proxy", os.Getenv("HTTPS_PROXY"), "env HTTPS_PROXY")
+	case os.Getenv("HTTP_PROXY") != "":
+		add("proxy", os.Getenv("HTTP_PROXY"), "env HTTP_PROXY")
+	default:
+		add("proxy", "none", "default")
+	}
+	switch {
+	case set["no-links"]:
+		add("links", "off", "flag")
+	case os.Getenv("NO_COLOR") != "":
+		add("links", "off", "env NO_COLOR")
+	default:
+		add("links", "on in terminals", "default")
+	}
+	writeColumns(os.Stdout, rows, nil, -1)
+}
+
+// Tells where os.UserConfigDir takes the config directory from.
+func configDirSource() string {
+	switch runtime.GOOS {
+	case "windows":
+		return "env AppData"
+	case "darwin", "ios", "plan9":
+		return "default"
+	}
+	if os.Getenv("XDG_CONFIG_HOME") != "" {
+		return "env XDG_CONFIG_HOME"
+	}
+	return "default"
+}
+
+func fileExists(file string) bool {
+	_, err := os.Stat(file)
+	return !errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"google.golang.org/api/tasks/v1"
+)
+
+// Inserts a copy of task into a tasklist, under parent and after previous
+// if given. Title, notes, due date and status are carried over. The API
+// ignores the completion time on insert, so for completed tasks it is set
+// by a follow-up update. Should the API refuse that too, the copy ends up
+// completed at the time it was made. Errors are final, so that callers
+// retrying the copy, like forEachTask does, don't insert it again.
+func insertCopy(srv *tasks.Service, tasklistId string, task *tasks.Task, parent, previous string) (*tasks.Task, error) {
+	call := srv.Tasks.Insert(tasklistId, &tasks.Task{
+		Title:  task.Title,
+		Notes:  task.Notes,
+		Due:    task.Due,
+		Status: task.Status,
+	})
+	if parent != "" {
+		call = call.Parent(parent)
+	}
+	if previous != "" {
+		call = call.Previous(previous)
+	}
+	var inserted *tasks.Task
+	err := withBackoff(func() (err error) {
+		inserted, err = call.Do()
+		return err
+	})
+	if err != nil {
+		return nil, finalError{err}
+	}
+	if task.Completed == nil || inserted.Completed != nil && *inserted.Completed == *task.Completed {
+		return inserted, nil
+	}
+	inserted.Status = "completed"
+	inserted.Completed = task.Completed
+	err = withBackoff(func() (err error) {
+		var updated *tasks.Task
+		updated, err = srv.Tasks.Update(tasklistId, inserted.Id, inserted).Do()
+		if err == nil {
+			inserted = updated
+		}
+		return err
+	})
+	if err != nil {
+		return inserted, finalError{err}
+	}
+	return inserted, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+const dueFormats = `Expected one of:
+  RFC3339    2024-06-01T00:00:00Z
+  date       2024-06-01
+  shorthand  today, tomorrow, monday ... sunday
+  offset     +30m, +2h, +3d, +2w, +1mo, the + may be left out
+  now
+optionally followed by a time of day like 17:00 or 5pm`
+
+// clockLayouts are the accepted layouts for the time of day of a due.
+var clockLayouts = []string{"15:04", "3:04pm", "3pm"}
+
+// Returns the current time relative dates are based on. The hidden --now
+// flag pins it, so that scripts and tests get the same dates every time.
+func currentTime() time.Time {
+	if *fixedNow == "" {
+		return time.Now()
+	}
+	t, err := time.Parse(time.RFC3339, *fixedNow)
+	if err != nil {
+		usageError("Invalid --now timestamp: %v", err)
+	}
+	return t
+}
+
+// Returns the timezone dates without an explicit offset are interpreted in.
+func location() *time.Location {
+	if *utc {
+		return time.UTC
+	}
+	return time.Local
+}
+
+// Parses a due date given on the command line and normalizes it to the
+// RFC3339 form the API expects. Google only stores the date of a due, so the
+// result is always midnight UTC of that date.
+func parseDue(s string) (string, error) {
+	due, _, err := parseDueTime(s)
+	return due, err
+}
+
+// Like parseDue, but also returns the time of day as HH:MM if one was given,
+// e.g. "today 17:00" or "now". Otherwise the time is empty.
+func parseDueTime(s string) (string, string, error) {
+	s = strings.TrimSpace(s)
+	if strings.EqualFold(s, "now") {
+		now := currentTime().In(location())
+		return formatDue(now), now.Format("15:04"), nil
+	}
+	date, clock := s, ""
+	if i := strings.LastIndexByte(s, ' '); i > 0 {
+		if c, ok := parseClock(s[i+1:]); ok {
+			date, clock = strings.TrimSpace(s[:i]), c
+		}
+	}
+	if due, ok := parseDate(date); ok {
+		return due, clock, nil
+	}
+	// Offsets are written like those of snooze, the + is optional.
+	o, err := parseOffset(strings.TrimPrefix(date, "+"))
+	if err != nil || date == "+" {
+		return "", "", fmt.Errorf("Invalid due date %q\n%s", s, dueFormats)
+	}
+	t := o.from(currentTime().In(location()))
+	if clock == "" && o.subDay() {
+		clock = t.Format("15:04")
+	}
+	return formatDue(t), clock, nil
+}
+
+func parseClock(s string) (string, bool) {
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, strings.ToLower(s)); err == nil {
+			return t.Format("15:04"), true
+		}
+	}
+	return "", false
+}
+
+func parseDate(s string) (string, bool) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return formatDue(t), true
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, location()); err == nil {
+		return formatDue(t), true
+	}
+	if t, ok := parseShorthand(strings.ToLower(s), currentTime().In(location())); ok {
+		return formatDue(t), true
+	}
+	return "", false
+}
+
+// Resolves shorthand dates like "tomorrow" or "friday" relative to now.
+func parseShorthand(s string, now time.Time) (time.Time, bool) {
+	switch s {
+	case "today":
+		return now, true
+	case "tomorrow":
+		return now.AddDate(0, 0, 1), true
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if s == strings.ToLower(d.String()) {
+			days := (int(d) - int(now.Weekday()) + 7) % 7
+			if days == 0 {
+				days = 7
+			}
+			return now.AddDate(0, 0, days), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// offset is a relative amount of time like "3d" or "1mo". Months and days
+// are kept apart from the rest, as they vary in length.
+type offset struct {
+	months, days int
+	d            time.Duration
+}
+
+// Parses an offset: a number followed by mo for months, w for weeks or d
+// for days, or anything time.ParseDuration accepts, like 30m or 2h.
+func parseOffset(s string) (offset, error) {
+	s = strings.TrimSpace(s)
+	for _, unit := range []struct {
+		suffix       string
+		months, days int
+	}{{"mo", 1, 0}, {"w", 0, 7}, {"d", 0, 1}} {
+		if n, ok := strings.CutSuffix(s, unit.suffix); ok && n != "" {
+			v, err := strconv.Atoi(n)
+			if err != nil {
+				return offset{}, fmt.Errorf("invalid duration %q", s)
+			}
+			return offset{months: v * unit.months, days: v * unit.days}, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return offset{}, fmt.Errorf("invalid duration %q", s)
+	}
+	return offset{d: d}, nil
+}
+
+// Reports whether the offset has a part shorter than a day.
+func (o offset) subDay() bool {
+	return o.d%(24*time.Hour) != 0
+}
+
+// Returns t moved by the offset. Adding months keeps the day of the month
+// where possible and otherwise ends up on the last day of the month, so
+// January 31 plus a month is the end of February.
+func (o offset) from(t time.Time) time.Time {
+	if o.months != 0 {
+		first := time.Date(t.Year(), t.Month()+time.Month(o.months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+		last := first.AddDate(0, 1, -1).Day()
+		t = first.AddDate(0, 0, min(t.Day(), last)-1)
+	}
+	return t.AddDate(0, 0, o.days).Add(o.d)
+}
+
+// Parses a duration like parseOffset does, measured from now.
+func parseDuration(s string) (time.Duration, error) {
+	o, err := parseOffset(s)
+	if err != nil {
+		return 0, err
+	}
+	now := currentTime()
+	return o.from(now).Sub(now), nil
+}
+
+// datePresets are the named layouts accepted by --date-format.
+var datePresets = map[string]string{
+	"iso": time.DateOnly,
+	"us":  "01/02/2006",
+	"eu":  "02.01.2006",
+}
+
+func dateLayout() string {
+	if layout, ok := datePresets[*dateFormat]; ok {
+		return layout
+	}
+	return *dateFormat
+}
+
+func timeLayout() string {
+	switch *timeFormat {
+	case "24h":
+		return "15:04"
+	case "12h":
+		return "3:04 PM"
+	}
+	return *timeFormat
+}
+
+// Renders a due date for display. Dues are dates stored as midnight UTC, so
+// unlike other timestamps they are not converted to the local timezone.
+func displayDue(due string) string {
+	t, err := time.Parse(time.RFC3339, due)
+	if err != nil {
+		return due
+	}
+	return t.UTC().Format(dateLayout())
+}
+
+// Renders a timestamp like the completion time of a task for display.
+func displayTime(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return timestamp
+	}
+	return t.In(location()).Format(dateLayout() + " " + timeLayout())
+}
+
+// Renders the time since a timestamp in the largest whole unit, like "3d
+// ago". Give or take clock skew, timestamps in the future count as now.
+func age(timestamp string) string {
+	t, err := time.Parse(time.RFC3339, timestamp)
+	if err != nil {
+		return ""
+	}
+	d := currentTime().Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	case d < 7*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%dw ago", int(d/(7*24*time.Hour)))
+}
+
+func formatDue(t time.Time) string {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+}
+
+// Google ignores the time of a due, so it is kept in the notes of the task
+// as a line like "[due 17:00]".
+var dueTimePattern = regexp.MustCompile(`(?m)^\[due (\d{2}:\d{2})\]\n?`)
+
+// Adds the due time marker to notes.
+func withDueTime(notes, clock string) string {
+	if clock == "" {
+		return notes
+	}
+	marker := "[due " + clock + "]"
+	if notes == "" {
+		return marker
+	}
+	return notes + "\n" + marker
+}
+
+// Returns the due time kept in the notes of a task, or an empty string.
+func dueTime(task *tasks.Task) string {
+	if m := dueTimePattern.FindStringSubmatch(task.Notes); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Returns the notes of a task without the due time marker.
+func stripDueTime(notes string) string {
+	return strings.TrimRight(dueTimePattern.ReplaceAllString(notes, ""), "\n")
+}
+
+// Renders the due of a task for display, including the time of day if the
+// notes have one.
+func displayTaskDue(task *tasks.Task) string {
+	if task.Due == "" {
+		return ""
+	}
+	return withClock(displayDue(task.Due), task)
+}
+
+// Adds the due time kept in the notes of a task to a rendered due date.
+func withClock(due string, task *tasks.Task) string {
+	if clock := dueTime(task); clock != "" {
+		if t, err := time.Parse("15:04", clock); err == nil {
+			due += " " + t.Format(timeLayout())
+		}
+	}
+	return due
+}
+
+// relativeLimit is how many days ahead dues are still shown relative to
+// today with --relative-dates. Dues further out are clearer as dates.
+const relativeLimit = 14
+
+// Like displayTaskDue, but with --relative-dates the date is shown relative
+// to today in the local timezone, like "overdue by 2d", "today", "tomorrow"
+// or "in 5d". This is for output meant to be read, not parsed.
+func humanTaskDue(task *tasks.Task) string {
+	if !*relDates || task.Due == "" {
+		return displayTaskDue(task)
+	}
+	t, err := time.Parse(time.RFC3339, task.Due)
+	if err != nil {
+		return displayTaskDue(task)
+	}
+	now := currentTime().In(location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(t.UTC().Sub(today).Round(time.Hour).Hours() / 24)
+	var due string
+	switch {
+	case days < 0:
+		due = fmt.Sprintf("overdue by %dd", -days)
+	case days == 0:
+		due = "today"
+	case days == 1:
+		due = "tomorrow"
+	case days <= relativeLimit:
+		due = fmt.Sprintf("in %dd", days)
+	default:
+		return displayTaskDue(task)
+	}
+	return withClock(due, task)
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// pinNow fixes the current time like --now does, in UTC, for the duration
+// of a test.
+func pinNow(t *testing.T, now string) {
+	t.Helper()
+	oldNow, oldUTC := *fixedNow, *utc
+	*fixedNow, *utc = now, true
+	t.Cleanup(func() { *fixedNow, *utc = oldNow, oldUTC })
+}
+
+func TestParseDueTime(t *testing.T) {
+	// A Wednesday.
+	pinNow(t, "2024-06-05T10:00:00Z")
+	tests := []struct {
+		in, due, clock string
+	}{
+		{"2024-06-01T00:00:00Z", "2024-06-01T00:00:00Z", ""},
+		{"2024-06-01T23:30:00+02:00", "2024-06-01T00:00:00Z", ""},
+		{"2024-06-01", "2024-06-01T00:00:00Z", ""},
+		{" 2024-06-01 ", "2024-06-01T00:00:00Z", ""},
+		{"today", "2024-06-05T00:00:00Z", ""},
+		{"Tomorrow", "2024-06-06T00:00:00Z", ""},
+		{"friday", "2024-06-07T00:00:00Z", ""},
+		{"monday", "2024-06-10T00:00:00Z", ""},
+		{"wednesday", "2024-06-12T00:00:00Z", ""},
+		{"now", "2024-06-05T00:00:00Z", "10:00"},
+		{"+3d", "2024-06-08T00:00:00Z", ""},
+		{"+2w", "2024-06-19T00:00:00Z", ""},
+		{"+1mo", "2024-07-05T00:00:00Z", ""},
+		{"+2h", "2024-06-05T00:00:00Z", "12:00"},
+		{"+30m", "2024-06-05T00:00:00Z", "10:30"},
+		{"+15h", "2024-06-06T00:00:00Z", "01:00"},
+		{"tomorrow 17:00", "2024-06-06T00:00:00Z", "17:00"},
+		{"2024-06-01 5pm", "2024-06-01T00:00:00Z", "17:00"},
+		{"friday 3:30pm", "2024-06-07T00:00:00Z", "15:30"},
+		{"+3d 9:00", "2024-06-08T00:00:00Z", "09:00"},
+		{"+2h 8am", "2024-06-05T00:00:00Z", "08:00"},
+		{"3d", "2024-06-08T00:00:00Z", ""},
+		{"2h", "2024-06-05T00:00:00Z", "12:00"},
+		{"1mo 9:00", "2024-07-05T00:00:00Z", "09:00"},
+	}
+	for _, tt := range tests {
+		due, clock, err := parseDueTime(tt.in)
+		if err != nil {
+			t.Errorf("parseDueTime(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if due != tt.due || clock != tt.clock {
+			t.Errorf("parseDueTime(%q) = %q, %q, want %q, %q", tt.in, due, clock, tt.due, tt.clock)
+		}
+	}
+}
+
+func TestParseDueTimeInvalid(t *testing.T) {
+	pinNow(t, "2024-06-05T10:00:00Z")
+	for _, in := range []string{
+		"",
+		"yesterday",
+		"2024-13-01",
+		"2024-02-30",
+		"06/01/2024",
+		"+3x",
+		"+d",
+		"+",
+		"tomorrow 25:00",
+		"17:00",
+		"next friday",
+	} {
+		if due, clock, err := parseDueTime(in); err == nil {
+			t.Errorf("parseDueTime(%q) = %q, %q, want an error", in, due, clock)
+		}
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	pinNow(t, "2024-06-05T10:00:00Z")
+	due, err := parseDue("tomorrow 17:00")
+	if err != nil || due != "2024-06-06T00:00:00Z" {
+		t.Errorf("parseDue(%q) = %q, %v, want %q", "tomorrow 17:00", due, err, "2024-06-06T00:00:00Z")
+	}
+	if _, err := parseDue("someday"); err == nil {
+		t.Errorf("parseDue(%q) succeeded, want an error", "someday")
+	}
+}
+
+func TestOffsetFrom(t *testing.T) {
+	tests := []struct {
+		from, offset, want string
+	}{
+		{"2023-01-31T09:00:00Z", "1mo", "2023-02-28T09:00:00Z"},
+		{"2024-01-31T09:00:00Z", "1mo", "2024-02-29T09:00:00Z"},
+		{"2024-01-30T09:00:00Z", "1mo", "2024-02-29T09:00:00Z"},
+		{"2024-01-29T09:00:00Z", "1mo", "2024-02-29T09:00:00Z"},
+		{"2024-01-28T09:00:00Z", "1mo", "2024-02-28T09:00:00Z"},
+		{"2024-03-31T09:00:00Z", "1mo", "2024-04-30T09:00:00Z"},
+		{"2023-12-15T09:00:00Z", "1mo", "2024-01-15T09:00:00Z"},
+		{"2023-12-31T09:00:00Z", "1mo", "2024-01-31T09:00:00Z"},
+		{"2023-12-31T09:00:00Z", "2mo", "2024-02-29T09:00:00Z"},
+		{"2023-11-30T09:00:00Z", "3mo", "2024-02-29T09:00:00Z"},
+		{"2024-01-31T09:00:00Z", "12mo", "2025-01-31T09:00:00Z"},
+		{"2024-02-29T09:00:00Z", "12mo", "2025-02-28T09:00:00Z"},
+		{"2024-12-31T09:00:00Z", "1d", "2025-01-01T09:00:00Z"},
+		{"2024-02-28T09:00:00Z", "1w", "2024-03-06T09:00:00Z"},
+		{"2024-12-31T23:30:00Z", "1h", "2025-01-01T00:30:00Z"},
+	}
+	for _, tt := range tests {
+		from, err := time.Parse(time.RFC3339, tt.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o, err := parseOffset(tt.offset)
+		if err != nil {
+			t.Fatalf("parseOffset(%q) failed: %v", tt.offset, err)
+		}
+		if got := o.from(from).Format(time.RFC3339); got != tt.want {
+			t.Errorf("%s + %s = %s, want %s", tt.from, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestSnoozed(t *testing.T) {
+	pinNow(t, "2024-06-05T10:00:00Z")
+	tests := []struct {
+		due, notes, by, want, wantNotes string
+	}{
+		{"2024-06-10T00:00:00.000Z", "", "2h", "2024-06-10T02:00:00Z", "[due 02:00]"},
+		{"2024-06-10T00:00:00.000Z", "", "3d", "2024-06-13T00:00:00Z", ""},
+		{"2024-06-10T00:00:00.000Z", "Call\n[due 17:00]", "30m", "2024-06-10T17:30:00Z", "Call\n[due 17:30]"},
+		{"2024-01-31T00:00:00.000Z", "[due 09:00]", "1mo", "2024-02-29T09:00:00Z", "[due 09:00]"},
+		{"2024-12-31T00:00:00.000Z", "", "1mo", "2025-01-31T00:00:00Z", ""},
+		{"", "", "2h", "2024-06-05T12:00:00Z", "[due 12:00]"},
+		{"", "", "1d", "2024-06-06T10:00:00Z", ""},
+	}
+	for _, tt := range tests {
+		o, err := parseOffset(tt.by)
+		if err != nil {
+			t.Fatalf("parseOffset(%q) failed: %v", tt.by, err)
+		}
+		got, notes := snoozed(&tasks.Task{Due: tt.due, Notes: tt.notes}, o)
+		if got.Format(time.RFC3339) != tt.want || notes != tt.wantNotes {
+			t.Errorf("snoozing %q %q by %s = %s, %q, want %s, %q", tt.due, tt.notes, tt.by, got.Format(time.RFC3339), notes, tt.want, tt.wantNotes)
+		}
+	}
+}
+
+func TestCurrentTimePinned(t *testing.T) {
+	pinNow(t, "2024-06-05T10:00:00Z")
+	if got := currentTime().Format(time.RFC3339); got != "2024-06-05T10:00:00Z" {
+		t.Errorf("currentTime() = %s with --now 2024-06-05T10:00:00Z", got)
+	}
+	tests := []struct {
+		timestamp, want string
+	}{
+		{"2024-06-05T09:59:30Z", "just now"},
+		{"2024-06-05T11:00:00Z", "just now"},
+		{"2024-06-05T09:15:00Z", "45m ago"},
+		{"2024-06-04T12:00:00Z", "22h ago"},
+		{"2024-06-02T10:00:00Z", "3d ago"},
+		{"2024-05-15T10:00:00Z", "3w ago"},
+	}
+	for _, tt := range tests {
+		if got := age(tt.timestamp); got != tt.want {
+			t.Errorf("age(%s) = %q, want %q", tt.timestamp, got, tt.want)
+		}
+	}
+}
+
+func TestHumanTaskDue(t *testing.T) {
+	pinNow(t, "2024-06-05T22:00:00Z")
+	old := *relDates
+	*relDates = true
+	t.Cleanup(func() { *relDates = old })
+	tests := []struct {
+		due, notes, want string
+	}{
+		{"2024-06-03T00:00:00.000Z", "", "overdue by 2d"},
+		{"2024-06-05T00:00:00.000Z", "", "today"},
+		{"2024-06-06T00:00:00.000Z", "[due 17:00]", "tomorrow 17:00"},
+		{"2024-06-10T00:00:00.000Z", "", "in 5d"},
+		{"2024-06-19T00:00:00.000Z", "", "in 14d"},
+		{"2024-06-20T00:00:00.000Z", "", "2024-06-20"},
+	}
+	for _, tt := range tests {
+		task := &tasks.Task{Due: tt.due, Notes: tt.notes}
+		if got := humanTaskDue(task); got != tt.want {
+			t.Errorf("humanTaskDue(%s) = %q, want %q", tt.due, got, tt.want)
+		}
+	}
+}
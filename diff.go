@@ -0,0 +1,195 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// listDiff holds what changed in a tasklist between two backups.
+type listDiff struct {
+	Id       string         `json:"id"`
+	Title    string         `json:"title"`
+	Status   string         `json:"status,omitempty"`
+	Added    []*tasks.Task  `json:"added,omitempty"`
+	Removed  []*tasks.Task  `json:"removed,omitempty"`
+	Modified []modifiedTask `json:"modified,omitempty"`
+}
+
+type modifiedTask struct {
+	Id     string   `json:"id"`
+	Title  string   `json:"title"`
+	Fields []string `json:"fields"`
+}
+
+// diffIgnored are the fields that change with every modification and so
+// tell nothing on their own.
+var diffIgnored = []string{"etag", "updated"}
+
+// Compares two backup files and prints the tasks added, removed and
+// modified in every tasklist, matched by id. Deleted tasks, as found in
+// delta backups, count as removed. With --format json, the differences are
+// printed as JSON instead.
+func diff(oldFile, newFile string) {
+	if oldFile == "" || newFile == "" {
+		usageError("Missing backup files to compare")
+	}
+	asJSON := flagGiven("format")
+	if asJSON && *format != "json" {
+		usageError("Unknown diff format: %s, expected --format json", *format)
+	}
+	from, to := readBackup(oldFile), readBackup(newFile)
+
+	var diffs []listDiff
+	oldLists := make(map[string]backupList)
+	for _, list := range from.Tasklists {
+		oldLists[list.Id] = list
+	}
+	seen := make(map[string]bool)
+	for _, list := range to.Tasklists {
+		seen[list.Id] = true
+		d := diffList(oldLists[list.Id], list)
+		if _, ok := oldLists[list.Id]; !ok {
+			d.Status = "added"
+		}
+		if d.Status != "" || len(d.Added)+len(d.Removed)+len(d.Modified) > 0 {
+			diffs = append(diffs, d)
+		}
+	}
+	for _, list := range from.Tasklists {
+		if !seen[list.Id] {
+			d := diffList(list, backupList{Id: list.Id, Title: list.Title})
+			d.Status = "removed"
+			diffs = append(diffs, d)
+		}
+	}
+
+	if asJSON {
+		bs, err := json.Marshal(diffs)
+		if err != nil {
+			fatalf("Failure when marshaling diff: %v", err)
+		}
+		fmt.Print(string(bs))
+		return
+	}
+	for i, d := range diffs {
+		if i > 0 {
+			fmt.Println()
+		}
+		if d.Status != "" {
+			fmt.Printf("%s (%s)\n", d.Title, d.Status)
+		} else {
+			fmt.Println(d.Title)
+		}
+		for _, task := range d.Added {
+			fmt.Printf("+ %s  %s\n", task.Title, task.Id)
+		}
+		for _, task := range d.Removed {
+			fmt.Printf("- %s  %s\n", task.Title, task.Id)
+		}
+		for _, m := range d.Modified {
+			fmt.Printf("~ %s  %s: %s\n", m.Title, m.Id, strings.Join(m.Fields, ", "))
+		}
+	}
+}
+
+func diffList(from, to backupList) listDiff {
+	d := listDiff{Id: to.Id, Title: to.Title}
+	oldTasks := make(map[string]*tasks.Task)
+	for _, task := range from.Tasks {
+		if !task.Deleted {
+			oldTasks[task.Id] = task
+		}
+	}
+	seen := make(map[string]bool)
+	for _, task := range to.Tasks {
+		if task.Deleted {
+			continue
+		}
+		seen[task.Id] = true
+		before := oldTasks[task.Id]
+		if before == nil {
+			d.Added = append(d.Added, task)
+			continue
+		}
+		if fields := changedFields(before, task); len(fields) > 0 {
+			d.Modified = append(d.Modified, modifiedTask{Id: task.Id, Title: task.Title, Fields: fields})
+		}
+	}
+	for _, task := range from.Tasks {
+		if !task.Deleted && !seen[task.Id] {
+			d.Removed = append(d.Removed, task)
+		}
+	}
+	return d
+}
+
+// Returns the names of the JSON fields that differ between two versions of
+// a task, sorted.
+func changedFields(a, b *tasks.Task) []string {
+	fa, fb := taskFields(a), taskFields(b)
+	var fields []string
+	for name := range fa {
+		if _, ok := fb[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	for name, value := range fb {
+		if !reflect.DeepEqual(fa[name], value) {
+			fields = append(fields, name)
+		}
+	}
+	fields = slices.DeleteFunc(fields, func(name string) bool { return slices.Contains(diffIgnored, name) })
+	slices.Sort(fields)
+	return fields
+}
+
+func taskFields(task *tasks.Task) map[string]any {
+	bs, _ := json.Marshal(task)
+	var fields map[string]any
+	json.Unmarshal(bs, &fields)
+	return fields
+}
+
+func readBackup(file string) backupFile {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		fatalf("Could not read backup: %v", err)
+	}
+	var bf backupFile
+	if err := json.Unmarshal(b, &bf); err != nil {
+		fatalf("Could not parse %s: %v", file, err)
+	}
+	return bf
+}
+
+// Returns the update times of the tasks in a backup by id. Tasks deleted by
+// the time of the backup are left out.
+func backupUpdates(bf backupFile) map[string]string {
+	updates := make(map[string]string)
+	for _, list := range bf.Tasklists {
+		for _, task := range list.Tasks {
+			if !task.Deleted {
+				updates[task.Id] = task.Updated
+			}
+		}
+	}
+	return updates
+}
+
+// Returns the tasks added or modified since the backup the update times are
+// from. With no update times, for no backup, all tasks are returned.
+func changedSince(items []*tasks.Task, updates map[string]string) []*tasks.Task {
+	if updates == nil {
+		return items
+	}
+	return slices.DeleteFunc(items, func(task *tasks.Task) bool {
+		before, ok := updates[task.Id]
+		return ok && laterTimestamp(before, task.Updated) == before
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/option"
+	"google.golang.org/api/tasks/v1"
+)
+
+// Checks the setup step by step and prints a line for each check, with a
+// hint on how to fix what failed. Unlike other commands, doctor never starts
+// the authorization flow.
+func doctor(ctx context.Context) {
+	failed := false
+	check := func(ok bool, msg, hint string) bool {
+		if ok {
+			fmt.Printf("[ok]   %s\n", msg)
+		} else {
+			fmt.Printf("[fail] %s\n       %s\n", msg, hint)
+			failed = true
+		}
+		return ok
+	}
+	defer func() {
+		if failed {
+			exit(exitFailure)
+		}
+	}()
+
+	dir := getConfigDir()
+	info, err := os.Stat(dir)
+	check(err == nil && info.IsDir(), "Config directory "+dir+" exists",
+		"Create it with: mkdir -p "+dir)
+
+	credentialsFile := filepath.Join(dir, "credentials.json")
+	b, err := os.ReadFile(credentialsFile)
+	source := credentialsFile
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv("GTASKS_CREDENTIALS") != "" {
+		b, err, source = []byte(os.Getenv("GTASKS_CREDENTIALS")), nil, "GTASKS_CREDENTIALS"
+	}
+	if !check(err == nil, "Credentials found in "+source,
+		"Create an OAuth client in the Google Cloud console and download it to "+credentialsFile) {
+		return
+	}
+	config, err := google.ConfigFromJSON(b, tasks.TasksScope)
+	if !check(err == nil, "Credentials can be parsed",
+		fmt.Sprintf("Download the credentials of a desktop OAuth client again: %v", err)) {
+		return
+	}
+
+	tokenFile := filepath.Join(dir, "token.json")
+	tok, source := &storedToken{}, tokenFile
+	if env := os.Getenv("GTASKS_TOKEN"); env != "" {
+		err, source = json.Unmarshal([]byte(env), tok), "GTASKS_TOKEN"
+	} else {
+		unlock := lockFile(tokenFile)
+		tok, err = tokenFromFile(tokenFile)
+		unlock()
+	}
+	if !check(err == nil, "Token found in "+source,
+		fmt.Sprintf("Run any command like 'gtasks list --all' to authorize: %v", err)) {
+		return
+	}
+	usable := tok.Valid() || tok.RefreshToken != ""
+	if !check(usable, "Token is not expired",
+		"Delete "+tokenFile+" and run gtasks again to re-authorize") {
+		return
+	}
+
+	srv, err := tasks.NewService(ctx, option.WithHTTPClient(config.Client(ctx, &tok.Token)))
+	if err == nil {
+		_, err = srv.Tasklists.List().MaxResults(1).Do()
+	}
+	var retrieve *oauth2.RetrieveError
+	hint := fmt.Sprintf("Check your network connection and proxy settings: %v", err)
+	if errors.As(err, &retrieve) {
+		hint = "The token was revoked or has expired, delete " + tokenFile + " and run gtasks again"
+	}
+	check(err == nil, "Tasklists can be retrieved", hint)
+}
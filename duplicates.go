@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Reports pending tasks with the same title within a tasklist. Titles are
+// compared ignoring case and surrounding whitespace. With --delete-extra,
+// all but the oldest task of each group are deleted after confirmation.
+// The API doesn't tell when a task was created, so the one updated longest
+// ago counts as the oldest.
+func findDuplicates(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	var extra []string
+	tasklistIds := make(map[string]string)
+	for _, tasklist := range tasklists {
+		groups := make(map[string][]*tasks.Task)
+		var titles []string
+		for _, task := range fetchTasks(srv, tasklist.Id) {
+			if task.Status == "completed" {
+				continue
+			}
+			title := titleKey(task.Title)
+			if groups[title] == nil {
+				titles = append(titles, title)
+			}
+			groups[title] = append(groups[title], task)
+		}
+		for _, title := range titles {
+			group := groups[title]
+			if len(group) < 2 {
+				continue
+			}
+			slices.SortFunc(group, func(a, b *tasks.Task) int {
+				return strings.Compare(a.Updated, b.Updated)
+			})
+			fmt.Printf("%s: %q (%d)\n", tasklist.Title, group[0].Title, len(group))
+			for _, task := range group {
+				fmt.Printf("  %s\n", task.Id)
+			}
+			for _, task := range group[1:] {
+				extra = append(extra, task.Id)
+				tasklistIds[task.Id] = tasklist.Id
+			}
+		}
+	}
+	if !*deleteExtra || len(extra) == 0 {
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("Delete %d duplicate tasks, keeping the oldest of each?", len(extra)), len(extra)) {
+		return
+	}
+	results := forEachTask(extra, *concurrency, func(taskId string) error {
+		return srv.Tasks.Delete(tasklistIds[taskId], taskId).Do()
+	})
+	summarize("delete", results)
+}
+
+// Returns the key titles are compared by when looking for duplicates,
+// ignoring case and surrounding whitespace.
+func titleKey(title string) string {
+	return strings.ToLower(strings.TrimSpace(title))
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Deletes every task of a tasklist, pending and completed, but keeps the
+// list itself. Only top level tasks are deleted, as their subtasks go with
+// them.
+func empty(srv *tasks.Service, tasklistId, title string) {
+	items := fetchTasks(srv, tasklistId)
+	if len(items) == 0 {
+		fmt.Println("Nothing to delete")
+		return
+	}
+	byId := tasksById(items)
+	var taskIds []string
+	for _, task := range items {
+		if byId[task.Parent] == nil {
+			taskIds = append(taskIds, task.Id)
+		}
+	}
+	if *dryRun {
+		fmt.Printf("Would delete %d tasks from %s\n", len(items), title)
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("Delete all %d tasks of %s?", len(items), title), len(items)) {
+		return
+	}
+	results := forEachTask(taskIds, *concurrency, func(taskId string) error {
+		return srv.Tasks.Delete(tasklistId, taskId).Do()
+	})
+	deleted := make(map[string]bool)
+	for _, r := range results {
+		deleted[r.taskId] = r.err == nil
+	}
+	count := 0
+	for _, task := range items {
+		if deleted[rootOf(byId, task).Id] {
+			count++
+		}
+	}
+	fmt.Printf("Deleted %d tasks\n", count)
+	summarize("delete", results)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+
+	"google.golang.org/api/googleapi"
+)
+
+// Exit codes, also reported as "code" with --json-errors.
+const (
+	exitFailure = 1
+	exitUsage   = 2
+	// Exit code of list and search with --fail-if-empty when nothing matched.
+	exitEmpty = 3
+	// Exit code when interrupted, like shells report for SIGINT.
+	exitInterrupted = 130
+	// Exit code when --overall-timeout ran out, like timeout(1) uses.
+	exitTimedOut = 124
+)
+
+// jsonError is what --json-errors prints to stderr on failure. Status is
+// the HTTP status of the failed API call, if the failure was one.
+type jsonError struct {
+	Error  string `json:"error"`
+	Code   int    `json:"code"`
+	Status int    `json:"status,omitempty"`
+}
+
+// Prints the error as a JSON object to stderr. The values formatted into
+// the message are searched for an API error to report its HTTP status.
+func printJSONError(msg string, code int, v ...any) {
+	je := jsonError{Error: msg, Code: code}
+	for _, arg := range v {
+		var apiErr *googleapi.Error
+		if err, ok := arg.(error); ok && errors.As(err, &apiErr) {
+			je.Status = apiErr.Code
+		}
+	}
+	bs, _ := json.Marshal(je)
+	fmt.Fprintln(os.Stderr, string(bs))
+}
+
+// Returns advice for the first of the values that is a common network
+// error, like a failed DNS lookup, or an empty string if there is none.
+func networkAdvice(v ...any) string {
+	for _, arg := range v {
+		err, ok := arg.(error)
+		if !ok {
+			continue
+		}
+		var dnsErr *net.DNSError
+		var certErr *tls.CertificateVerificationError
+		var authorityErr x509.UnknownAuthorityError
+		var netErr net.Error
+		switch {
+		case errors.As(err, &dnsErr):
+			return fmt.Sprintf("Could not look up %s, check your internet connection", dnsErr.Name)
+		case errors.As(err, &certErr) || errors.As(err, &authorityErr):
+			return "The certificate of the server could not be verified, a proxy may be intercepting the connection to Google"
+		case errors.Is(err, syscall.ECONNREFUSED):
+			if *proxy != "" || os.Getenv("HTTPS_PROXY") != "" {
+				return "The connection was refused, check that the proxy is running"
+			}
+			return "The connection was refused, a firewall or proxy may be blocking tasks.googleapis.com"
+		case errors.As(err, &netErr) && netErr.Timeout() && !errors.Is(err, context.DeadlineExceeded):
+			return "The connection timed out, check your internet connection or raise --dial-timeout and --response-timeout"
+		}
+	}
+	return ""
+}
+
+// Reports a usage error, with the usage or as JSON, and exits.
+func usageError(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	if inBatch {
+		panic(batchFailure{msg: msg, code: exitUsage})
+	}
+	if *jsonErrors {
+		printJSONError(msg, exitUsage)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s\n\n", msg)
+		flag.Usage()
+	}
+	exit(exitUsage)
+}
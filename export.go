@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// priorityPattern finds a Todoist priority given in the notes of a task as
+// a line like "[p1]", p1 being the highest.
+var priorityPattern = regexp.MustCompile(`(?m)^\[p([1-4])\]\n?`)
+
+// todoistProject and todoistTask are the shape of the JSON export, following
+// the names of the Todoist API.
+type todoistProject struct {
+	Name  string         `json:"name"`
+	Items []*todoistTask `json:"items"`
+}
+
+type todoistTask struct {
+	Content     string         `json:"content"`
+	Description string         `json:"description,omitempty"`
+	Priority    int            `json:"priority"`
+	Due         *todoistDue    `json:"due,omitempty"`
+	Checked     bool           `json:"checked"`
+	CompletedAt string         `json:"completed_at,omitempty"`
+	Children    []*todoistTask `json:"children,omitempty"`
+}
+
+type todoistDue struct {
+	Date string `json:"date"`
+}
+
+// csvColumns is the header of --format csv exports. Columns are only ever
+// added at the end, so scripts can rely on the order.
+var csvColumns = []string{"id", "list", "title", "notes", "status", "due", "completed", "parent"}
+
+// Exports the tasks of the given tasklists in a format other tools can
+// import. With --format todoist, files ending in .csv get Todoist's CSV
+// import format with one section per tasklist, anything else JSON with one
+// project per tasklist. --format csv writes a plain CSV file with the
+// columns of csvColumns, --format html a page to be read in a browser.
+func export(srv *tasks.Service, tasklists []*tasks.TaskList, file string) {
+	if *format != "todoist" && *format != "csv" && *format != "html" {
+		usageError("Unknown export format: %s, expected --format todoist, csv or html", *format)
+	}
+	if file == "" {
+		fatalf("Missing export file")
+	}
+	switch *format {
+	case "csv":
+		writeCSVExport(srv, tasklists, file)
+		return
+	case "html":
+		writeHTMLExport(srv, tasklists, file)
+		return
+	}
+	var projects []todoistProject
+	for _, tasklist := range tasklists {
+		project := todoistProject{Name: tasklist.Title}
+		// Subtasks follow their parent in flatten's order, so the last task
+		// seen at each depth is the parent of the next one a level deeper.
+		var parents []*todoistTask
+		for _, node := range flatten(fetchTasks(srv, tasklist.Id)) {
+			task := toTodoist(node.task)
+			parents = append(parents[:node.depth], task)
+			if node.depth == 0 {
+				project.Items = append(project.Items, task)
+			} else {
+				parent := parents[node.depth-1]
+				parent.Children = append(parent.Children, task)
+			}
+		}
+		projects = append(projects, project)
+	}
+
+	if filepath.Ext(file) == ".csv" {
+		writeTodoistCSV(file, projects)
+		return
+	}
+	bs, err := json.MarshalIndent(projects, "", "  ")
+	if err != nil {
+		fatalf("Failure when marshaling export: %v", err)
+	}
+	if err := os.WriteFile(file, bs, 0600); err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+}
+
+func toTodoist(task *tasks.Task) *todoistTask {
+	t := &todoistTask{
+		Content:  task.Title,
+		Priority: 4,
+		Checked:  task.Status == "completed",
+	}
+	notes := stripDueTime(task.Notes)
+	if m := priorityPattern.FindStringSubmatch(notes); m != nil {
+		t.Priority, _ = strconv.Atoi(m[1])
+		notes = strings.TrimRight(priorityPattern.ReplaceAllString(notes, ""), "\n")
+	}
+	lines := markdownLinks(task)
+	if notes != "" {
+		lines = append([]string{notes}, lines...)
+	}
+	t.Description = strings.Join(lines, "\n")
+	if task.Due != "" {
+		date := task.Due[:10]
+		if clock := dueTime(task); clock != "" {
+			date += " " + clock
+		}
+		t.Due = &todoistDue{Date: date}
+	}
+	if task.Completed != nil {
+		t.CompletedAt = *task.Completed
+	}
+	return t
+}
+
+// Writes the tasks of the tasklists as CSV, subtasks right after their
+// parent. Dues and completion times are RFC3339 timestamps as the API has
+// them, notes are written as they are, due time marker included. With
+// --escape-newlines, titles and notes are escaped like in the plain format,
+// so that every task is on a line of its own. --include-parent-title adds a
+// last parent_title column, empty for top level tasks.
+func writeCSVExport(srv *tasks.Service, tasklists []*tasks.TaskList, file string) {
+	f, err := os.Create(file)
+	if err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	header := csvColumns
+	if *withParent {
+		header = append(slices.Clip(header), "parent_title")
+	}
+	w.Write(header)
+	for _, tasklist := range tasklists {
+		items := fetchTasks(srv, tasklist.Id)
+		byId := tasksById(items)
+		for _, node := range flatten(items) {
+			task := node.task
+			title, notes, parentTitle := task.Title, task.Notes, parentName(byId, task)
+			if *escapeNL {
+				title, notes, parentTitle = escapePlain(title), escapePlain(notes), escapePlain(parentTitle)
+			}
+			record := []string{task.Id, tasklist.Title, title, notes, task.Status, task.Due, completedTime(task), task.Parent}
+			if *withParent {
+				record = append(record, parentTitle)
+			}
+			w.Write(record)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+}
+
+// Writes the projects in Todoist's CSV template format. The format has no
+// way to mark tasks as completed, so completed tasks are left out, along
+// with their subtasks.
+func writeTodoistCSV(file string, projects []todoistProject) {
+	f, err := os.Create(file)
+	if err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	w.Write([]string{"TYPE", "CONTENT", "DESCRIPTION", "PRIORITY", "INDENT", "AUTHOR", "RESPONSIBLE", "DATE", "DATE_LANG", "TIMEZONE"})
+	var writeTasks func(items []*todoistTask, indent int)
+	writeTasks = func(items []*todoistTask, indent int) {
+		for _, t := range items {
+			if t.Checked {
+				continue
+			}
+			date := ""
+			if t.Due != nil {
+				date = t.Due.Date
+			}
+			w.Write([]string{"task", t.Content, t.Description, strconv.Itoa(t.Priority), strconv.Itoa(indent), "", "", date, "en", ""})
+			writeTasks(t.Children, indent+1)
+		}
+	}
+	for _, project := range projects {
+		if len(projects) > 1 {
+			w.Write([]string{"section", project.Name, "", "", "", "", "", "", "", ""})
+		}
+		writeTasks(project.Items, 1)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+}
+
+// htmlExport is the page written by --format html. It has no external
+// resources, so it can be sent around as a single file. html/template
+// escapes titles and notes, so they can't inject markup.
+var htmlExport = template.Must(template.New("export").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Tasks</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; padding: 0 1em; color: #202124; }
+h2 { border-bottom: 1px solid #dadce0; padding-bottom: .3em; }
+ul { list-style: none; padding-left: 0; }
+li { margin: .4em 0; }
+.completed .title { text-decoration: line-through; color: #80868b; }
+.due { margin-left: .5em; font-size: .85em; color: #1a73e8; }
+.notes { margin: .2em 0 0 1.7em; font-size: .85em; color: #5f6368; white-space: pre-wrap; }
+footer { margin-top: 3em; font-size: .8em; color: #80868b; }
+</style>
+</head>
+<body>
+{{range .Lists}}<h2>{{.Title}}</h2>
+<ul>
+{{range .Tasks}}<li{{if .Completed}} class="completed"{{end}} style="margin-left: {{.Indent}}em">
+<label><input type="checkbox" disabled{{if .Completed}} checked{{end}}> <span class="title">{{.Title}}</span></label>{{if .Due}}<span class="due">{{.Due}}</span>{{end}}
+{{if .Notes}}<div class="notes">{{.Notes}}</div>
+{{end}}</li>
+{{else}}<li>No tasks</li>
+{{end}}</ul>
+{{end}}<footer>Exported {{.Created}}</footer>
+</body>
+</html>
+`))
+
+type htmlList struct {
+	Title string
+	Tasks []htmlTask
+}
+
+type htmlTask struct {
+	Title, Notes, Due string
+	Completed         bool
+	Indent            int
+}
+
+// Writes the tasks of the tasklists as an HTML page with a checklist per
+// tasklist, subtasks indented under their parent.
+func writeHTMLExport(srv *tasks.Service, tasklists []*tasks.TaskList, file string) {
+	data := struct {
+		Lists   []htmlList
+		Created string
+	}{Created: displayTime(currentTime().Format(time.RFC3339))}
+	for _, tasklist := range tasklists {
+		list := htmlList{Title: tasklist.Title}
+		for _, node := range flatten(fetchTasks(srv, tasklist.Id)) {
+			task := node.task
+			list.Tasks = append(list.Tasks, htmlTask{
+				Title:     task.Title,
+				Notes:     stripDueTime(task.Notes),
+				Due:       displayTaskDue(task),
+				Completed: task.Status == "completed",
+				Indent:    2 * node.depth,
+			})
+		}
+		data.Lists = append(data.Lists, list)
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+	defer f.Close()
+	if err := htmlExport.Execute(f, data); err != nil {
+		fatalf("Could not write export: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+func TestCSVExport(t *testing.T) {
+	_, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"work": {
+			{Id: "a", Title: "Plan, then do", Notes: "line one\nline \"two\"", Status: "needsAction", Due: "2024-06-01T00:00:00.000Z"},
+			{Id: "b", Title: "Sub", Status: "completed", Parent: "a", Completed: ptr("2024-05-30T08:00:00.000Z")},
+		},
+	})
+	file := filepath.Join(t.TempDir(), "tasks.csv")
+	writeCSVExport(srv, []*tasks.TaskList{{Id: "work", Title: "Work, office"}}, file)
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header, _, _ := strings.Cut(string(b), "\n"); header != "id,list,title,notes,status,due,completed,parent" {
+		t.Errorf("header is %q", header)
+	}
+	for _, quoted := range []string{`"Work, office"`, `"Plan, then do"`, "\"line one\nline \"\"two\"\"\""} {
+		if !strings.Contains(string(b), quoted) {
+			t.Errorf("export doesn't contain %s:\n%s", quoted, b)
+		}
+	}
+
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		csvColumns,
+		{"a", "Work, office", "Plan, then do", "line one\nline \"two\"", "needsAction", "2024-06-01T00:00:00.000Z", "", ""},
+		{"b", "Work, office", "Sub", "", "completed", "", "2024-05-30T08:00:00.000Z", "a"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %q", len(records), len(want), records)
+	}
+	for i := range want {
+		if !slices.Equal(records[i], want[i]) {
+			t.Errorf("record %d is %q, want %q", i, records[i], want[i])
+		}
+	}
+}
+
+func TestCSVExportParentTitle(t *testing.T) {
+	_, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"work": {
+			{Id: "a", Title: "Plan", Status: "needsAction"},
+			{Id: "b", Title: "Sub", Status: "needsAction", Parent: "a"},
+		},
+	})
+	old := *withParent
+	*withParent = true
+	t.Cleanup(func() { *withParent = old })
+	file := filepath.Join(t.TempDir(), "tasks.csv")
+	writeCSVExport(srv, []*tasks.TaskList{{Id: "work", Title: "Work"}}, file)
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(strings.NewReader(string(b))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3: %q", len(records), records)
+	}
+	if got := records[0][len(records[0])-1]; got != "parent_title" || len(records[0]) != len(csvColumns)+1 {
+		t.Errorf("header is %q", records[0])
+	}
+	if records[1][8] != "" || records[2][8] != "Plan" {
+		t.Errorf("parent titles are %q and %q, want none and Plan", records[1][8], records[2][8])
+	}
+}
+
+func ptr(s string) *string {
+	return &s
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// maxPageSize is the most items the API returns per page.
+const maxPageSize = 100
+
+// Returns the page size given by --page-size, clamped to what the API
+// allows.
+func pageSize() int64 {
+	return int64(min(max(*perPage, 1), maxPageSize))
+}
+
+// Fetches all pages of a task listing.
+func allTasks(call *tasks.TasksListCall) ([]*tasks.Task, error) {
+	var items []*tasks.Task
+	err := forEachPage(call, func(page []*tasks.Task) {
+		items = append(items, page...)
+	})
+	return items, err
+}
+
+// Calls f with the tasks of every page of a task listing as it arrives.
+func forEachPage(call *tasks.TasksListCall, f func(page []*tasks.Task)) error {
+	return call.MaxResults(pageSize()).Pages(context.Background(), func(page *tasks.Tasks) error {
+		f(page.Items)
+		return nil
+	})
+}
+
+// Fetches every task of a tasklist, including completed and hidden ones.
+func fetchTasks(srv *tasks.Service, tasklistId string) []*tasks.Task {
+	items, err := allTasks(srv.Tasks.List(tasklistId).ShowCompleted(true).ShowHidden(true))
+	if err != nil {
+		fatalf("Could not list tasklist items: %v", err)
+	}
+	return items
+}
+
+// The file tasklists.json keeps the tasklists last fetched, to fall back on
+// when they can't be fetched.
+func tasklistsCacheFile() string {
+	return filepath.Join(getConfigDir(), "tasklists.json")
+}
+
+// Fetches all tasklists at startup, with retries. The tasklists fetched
+// last time are used instead if they are younger than --list-cache-ttl,
+// unless --refresh is given. If fetching fails, they are used regardless, so
+// that a brief outage doesn't stop commands that would get through.
+func loadTasklists(srv *tasks.Service) []*tasks.TaskList {
+	if *listTTL > 0 && !*refresh {
+		info, err := os.Stat(tasklistsCacheFile())
+		if err == nil && time.Since(info.ModTime()) < *listTTL {
+			if tasklists, err := readTasklistsCache(); err == nil {
+				return tasklists
+			}
+		}
+	}
+	var tasklists []*tasks.TaskList
+	err := withBackoff(func() error {
+		var err error
+		tasklists, err = allTasklists(srv)
+		return err
+	})
+	if err == nil {
+		if err := writeJSONFile(tasklistsCacheFile(), tasklists); err != nil {
+			logger.Info("could not cache tasklists", "error", err)
+		}
+		return tasklists
+	}
+	if interrupted.Err() != nil {
+		fatalf("Unable to retrieve tasks lists: %v", err)
+	}
+	tasklists, cacheErr := readTasklistsCache()
+	if cacheErr != nil {
+		fatalf("Unable to retrieve tasks lists: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Could not retrieve tasklists, using those from %s: %v\n", tasklistsCacheFile(), err)
+	return tasklists
+}
+
+func readTasklistsCache() ([]*tasks.TaskList, error) {
+	b, err := os.ReadFile(tasklistsCacheFile())
+	if err != nil {
+		return nil, err
+	}
+	var tasklists []*tasks.TaskList
+	err = json.Unmarshal(b, &tasklists)
+	return tasklists, err
+}
+
+// Drops the cached tasklists after tasklists were added, changed or
+// deleted, so that the next command fetches them again.
+func forgetTasklists() {
+	if err := os.Remove(tasklistsCacheFile()); err != nil && !os.IsNotExist(err) {
+		logger.Info("could not remove cached tasklists", "error", err)
+	}
+}
+
+// Fetches all tasklists.
+func allTasklists(srv *tasks.Service) ([]*tasks.TaskList, error) {
+	var items []*tasks.TaskList
+	err := srv.Tasklists.List().MaxResults(pageSize()).Pages(context.Background(), func(page *tasks.TaskLists) error {
+		items = append(items, page.Items...)
+		return nil
+	})
+	return items, err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Returns the pattern of a tag as written in tasks, #tag between whitespace
+// or at the start or end of a line. Tags are compared ignoring case, and may
+// end in characters other than letters, like #c++.
+func tagPattern(tag string) *regexp.Regexp {
+	tag = strings.TrimPrefix(tag, "#")
+	return regexp.MustCompile(`(?im)(^|\s)#` + regexp.QuoteMeta(tag) + `(\s|$)`)
+}
+
+// Reports whether the title or notes of a task contain the tag of pattern.
+func hasTag(task *tasks.Task, pattern *regexp.Regexp) bool {
+	return pattern.MatchString(task.Title) || pattern.MatchString(task.Notes)
+}
+
+// Keeps the top level tasks with --top-level, or the tasks no other task is
+// the parent of with --leaves. items must hold all tasks of a list for
+// --leaves.
+func filterHierarchy(items []*tasks.Task) []*tasks.Task {
+	if *topLevel && *leaves {
+		fatalf("Only one of --top-level and --leaves can be given")
+	}
+	if !*topLevel && !*leaves {
+		return items
+	}
+	parents := make(map[string]bool)
+	for _, task := range items {
+		parents[task.Parent] = true
+	}
+	var result []*tasks.Task
+	for _, task := range items {
+		if *topLevel && task.Parent == "" || *leaves && !parents[task.Id] {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// Returns whether only pending or only completed tasks are wanted, from
+// --status and its shortcuts --pending and --completed.
+func statusFilter() (bool, bool) {
+	switch *taskStatus {
+	case "all":
+	case "needsAction":
+		if *completed {
+			fatalf("--status needsAction can't be combined with --completed")
+		}
+		return true, false
+	case "completed":
+		if *pending {
+			fatalf("--status completed can't be combined with --pending")
+		}
+		return false, true
+	default:
+		usageError("Unknown status: %s, expected needsAction, completed or all", *taskStatus)
+	}
+	return *pending, *completed
+}
+
+// Keeps the tasks matching the filters given by --status, --pending,
+// --completed, --tag, --due-before and --notes-only.
+func filterTasks(items []*tasks.Task) []*tasks.Task {
+	before := ""
+	if *dueBefore != "" {
+		var err error
+		before, err = parseDue(*dueBefore)
+		if err != nil {
+			fatalf("%v", err)
+		}
+	}
+	pendingOnly, completedOnly := statusFilter()
+	var tagged *regexp.Regexp
+	if *tag != "" {
+		tagged = tagPattern(*tag)
+	}
+	var result []*tasks.Task
+	for _, task := range items {
+		if pendingOnly && task.Status == "completed" || completedOnly && task.Status != "completed" {
+			continue
+		}
+		if *notesOnly && strings.TrimSpace(task.Notes) == "" {
+			continue
+		}
+		if tagged != nil && !hasTag(task, tagged) {
+			continue
+		}
+		if before != "" && (task.Due == "" || task.Due[:10] >= before[:10]) {
+			continue
+		}
+		result = append(result, task)
+	}
+	return result
+}
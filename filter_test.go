@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+func TestHasTag(t *testing.T) {
+	tests := []struct {
+		tag, title, notes string
+		want              bool
+	}{
+		{"work", "Call #work", "", true},
+		{"#work", "#Work call", "", true},
+		{"work", "Call", "line\n#work\nmore", true},
+		{"work", "Call #workshop", "", false},
+		{"work", "Call a#work", "", false},
+		{"c++", "Learn #c++", "", true},
+		{"c++", "Learn #c++ today", "", true},
+		{"c++", "Learn #c+++", "", false},
+		{"c", "Learn #c++", "", false},
+	}
+	for _, tt := range tests {
+		task := &tasks.Task{Title: tt.title, Notes: tt.notes}
+		if got := hasTag(task, tagPattern(tt.tag)); got != tt.want {
+			t.Errorf("hasTag(%q, %q) = %v, want %v", tt.title+"|"+tt.notes, tt.tag, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Returns the output format, taking --plain and --template-file into
+// account.
+func outputFormat() string {
+	switch {
+	case *tmplFile != "":
+		return "template"
+	case *tree && !*flat:
+		return "tree"
+	case *plain:
+		return "plain"
+	}
+	return *format
+}
+
+// Writes tasks to stdout in the format selected by --format.
+func printTasks(items []*tasks.Task) {
+	defer stdout.Flush()
+	switch outputFormat() {
+	case "json":
+		bs, err := json.Marshal(jsonTasks(items))
+		if err != nil {
+			fatalf("Failure when marshaling items: %v", err)
+		}
+		fmt.Fprint(stdout, string(bs))
+	case "jsonl":
+		s := newJSONStream(stdout, true)
+		s.write(jsonTasks(items))
+		s.close()
+	case "table":
+		printTable(items)
+	case "plain":
+		printPlain(items)
+	case "template":
+		printTemplate(items)
+	case "tree":
+		printTree(items)
+	case "count":
+		fmt.Fprintln(stdout, len(items))
+	default:
+		fatalf("Unknown format: %s", *format)
+	}
+}
+
+// jsonStream writes tasks one at a time, so that they can be printed as they
+// are fetched: as a JSON array, or as JSON lines with one object per line.
+// If writing stops part way because of an error, the array is left unclosed
+// rather than looking complete.
+type jsonStream struct {
+	w     *bufio.Writer
+	enc   *json.Encoder
+	lines bool
+	n     int
+}
+
+func newJSONStream(w *bufio.Writer, lines bool) *jsonStream {
+	if !lines {
+		w.WriteString("[")
+	}
+	return &jsonStream{w: w, enc: json.NewEncoder(w), lines: lines}
+}
+
+func (s *jsonStream) write(values []any) {
+	for _, v := range values {
+		if s.n > 0 && !s.lines {
+			s.w.WriteString(",")
+		}
+		if err := s.enc.Encode(v); err != nil {
+			fatalf("Failure when marshaling items: %v", err)
+		}
+		s.n++
+	}
+	s.w.Flush()
+}
+
+func (s *jsonStream) close() {
+	if !s.lines {
+		s.w.WriteString("]\n")
+	}
+	s.w.Flush()
+}
+
+// Prints tasks grouped by tasklist. JSON output is an object keyed by the
+// tasklist titles, the other formats print each title before its tasks.
+func printGroups(groups []taskGroup) {
+	defer stdout.Flush()
+	if outputFormat() == "count" {
+		// A count is of all tasks, to be usable in a shell test.
+		n := 0
+		for _, group := range groups {
+			n += len(group.items)
+		}
+		fmt.Fprintln(stdout, n)
+		return
+	}
+	if outputFormat() == "jsonl" {
+		// JSON lines have no room for titles, the tasks are just printed.
+		for _, group := range groups {
+			printTasks(group.items)
+		}
+		return
+	}
+	if outputFormat() == "json" {
+		byTitle := make(map[string][]*tasks.Task)
+		for _, group := range groups {
+			byTitle[group.title] = append(byTitle[group.title], group.items...)
+		}
+		out := make(map[string]any)
+		for title, items := range byTitle {
+			out[title] = jsonTasks(items)
+		}
+		bs, err := json.Marshal(out)
+		if err != nil {
+			fatalf("Failure when marshaling items: %v", err)
+		}
+		fmt.Fprint(stdout, string(bs))
+		return
+	}
+	for i, group := range groups {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		fmt.Fprintf(stdout, "%s\n", group.title)
+		printTasks(group.items)
+	}
+}
+
+func printTable(items []*tasks.Task) {
+	header := []string{"ID", "STATUS", "DUE", "TITLE", "NOTES"}
+	if parentColumn() {
+		header = slices.Insert(header, 4, "PARENT")
+	}
+	if *showAge {
+		header = slices.Insert(header, 3, "MODIFIED")
+	}
+	if *showDone {
+		header = slices.Insert(header, 3, "COMPLETED")
+	}
+	rows := [][]string{header}
+	links := []string{""}
+	byId := tasksById(items)
+	for _, task := range items {
+		status := statusSymbol(task)
+		switch {
+		case task.Deleted:
+			status += " deleted"
+		case task.Hidden:
+			status += " hidden"
+		}
+		taskNotes := stripDueTime(task.Notes)
+		notes := []string{truncate(strings.Join(strings.Fields(taskNotes), " "), *notesWidth)}
+		if *wrap {
+			notes = wrapText(taskNotes, *notesWidth)
+		}
+		row := []string{task.Id, status, humanTaskDue(task), task.Title, notes[0]}
+		if parentColumn() {
+			row = slices.Insert(row, 4, parentName(byId, task))
+		}
+		if *showAge {
+			row = slices.Insert(row, 3, age(task.Updated))
+		}
+		if *showDone {
+			row = slices.Insert(row, 3, completedDisplay(task))
+		}
+		rows = append(rows, row)
+		links = append(links, task.WebViewLink)
+		for _, line := range notes[1:] {
+			row := make([]string, len(header))
+			row[len(row)-1] = line
+			rows = append(rows, row)
+			links = append(links, "")
+		}
+	}
+	if !hyperlinks() {
+		links = nil
+	}
+	writeColumns(stdout, rows, links, slices.Index(header, "TITLE"))
+}
+
+// Writes rows as columns separated by two spaces. If links is given, the
+// cell in column linkCol of row i is made a hyperlink to links[i]. Widths are
+// computed from the visible text only, so links don't break the alignment.
+func writeColumns(w io.Writer, rows [][]string, links []string, linkCol int) {
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i == len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+	for r, row := range rows {
+		var sb strings.Builder
+		for i, cell := range row {
+			if i > 0 {
+				sb.WriteString("  ")
+			}
+			text := cell
+			if i == linkCol && r < len(links) && links[r] != "" {
+				text = hyperlink(links[r], cell)
+			}
+			sb.WriteString(text)
+			if i < len(row)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+			}
+		}
+		fmt.Fprintln(w, strings.TrimRight(sb.String(), " "))
+	}
+}
+
+// Reports whether output should contain OSC 8 hyperlinks: only when stdout
+// is a terminal and neither NO_COLOR nor --no-links is set.
+func hyperlinks() bool {
+	if *noLinks || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+func hyperlink(url, text string) string {
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+// Prints one line per task with tab separated id, title, status and due,
+// without any decoration. With --flat or --include-parent-title, the title of
+// the parent follows, then the completion time with --show-completion.
+func printPlain(items []*tasks.Task) {
+	byId := tasksById(items)
+	for _, task := range items {
+		fmt.Fprintf(stdout, "%s\t%s\t%s\t%s", task.Id, escapePlain(task.Title), task.Status, displayTaskDue(task))
+		if parentColumn() {
+			fmt.Fprintf(stdout, "\t%s", escapePlain(parentName(byId, task)))
+		}
+		if *showDone {
+			fmt.Fprintf(stdout, "\t%s", completedDisplay(task))
+		}
+		fmt.Fprintln(stdout)
+	}
+}
+
+// Returns the marker for the status of a task in tables, trees and the tui,
+// --completed-symbol or --pending-symbol. With --no-unicode, symbols that
+// aren't plain ASCII are replaced by the defaults.
+func statusSymbol(task *tasks.Task) string {
+	symbol, fallback := *todoSymbol, "[ ]"
+	if task.Status == "completed" {
+		symbol, fallback = *doneSymbol, "[x]"
+	}
+	if *noUnicode && strings.ContainsFunc(symbol, func(r rune) bool { return r > unicode.MaxASCII }) {
+		return fallback
+	}
+	return symbol
+}
+
+// Reports whether the table and plain formats get a column with the title of
+// the parent of each task.
+func parentColumn() bool {
+	return *flat || *withParent
+}
+
+// Returns the tasks to marshal for the JSON format. With
+// --include-parent-title, every task gets a parentTitle field, empty for top
+// level tasks.
+func jsonTasks(items []*tasks.Task) []any {
+	out := make([]any, 0, len(items))
+	if !*withParent {
+		for _, task := range items {
+			out = append(out, task)
+		}
+		return out
+	}
+	byId := tasksById(items)
+	for _, task := range items {
+		bs, err := json.Marshal(task)
+		if err != nil {
+			fatalf("Failure when marshaling items: %v", err)
+		}
+		title, _ := json.Marshal(parentName(byId, task))
+		field := `"parentTitle":` + string(title)
+		if len(bs) > 2 {
+			field = "," + field
+		}
+		out = append(out, json.RawMessage(slices.Concat(bs[:len(bs)-1], []byte(field), []byte("}"))))
+	}
+	return out
+}
+
+// Returns the title of the parent of a subtask, or its id if the parent is
+// not among the tasks listed. Top level tasks have none.
+func parentName(byId map[string]*tasks.Task, task *tasks.Task) string {
+	if parent := byId[task.Parent]; parent != nil {
+		return parent.Title
+	}
+	return task.Parent
+}
+
+// plainEscaper escapes the characters that would break the line and field
+// structure of the plain format.
+var plainEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+func escapePlain(s string) string {
+	return plainEscaper.Replace(s)
+}
+
+// Shortens s to at most width runes, marking the cut with an ellipsis.
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width < 1 || len(r) <= width {
+		return s
+	}
+	return string(r[:width-1]) + "…"
+}
+
+// Breaks s into lines of at most width runes, at spaces where possible.
+// Line breaks in s are kept. There is always at least one line.
+func wrapText(s string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := []rune{}
+		for _, word := range strings.Fields(paragraph) {
+			w := []rune(word)
+			for width > 0 && len(w) > width {
+				if len(line) > 0 {
+					lines = append(lines, string(line))
+					line = []rune{}
+				}
+				lines = append(lines, string(w[:width]))
+				w = w[width:]
+			}
+			if len(line) > 0 && width > 0 && len(line)+1+len(w) > width {
+				lines = append(lines, string(line))
+				line = []rune{}
+			}
+			if len(line) > 0 {
+				line = append(line, ' ')
+			}
+			line = append(line, w...)
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// Prints tasks as a tree of subtasks with connecting lines, like the tree
+// command does, or indented by two spaces per level with --no-unicode.
+func printTree(items []*tasks.Task) {
+	// open[d] tells whether the ancestor at depth d has siblings still to
+	// come, so that its line continues past its subtasks.
+	var open []bool
+	for _, node := range flatten(items) {
+		var prefix strings.Builder
+		if *noUnicode {
+			prefix.WriteString(strings.Repeat("  ", node.depth))
+		} else if node.depth > 0 {
+			for _, more := range open[1:node.depth] {
+				if more {
+					prefix.WriteString("│  ")
+				} else {
+					prefix.WriteString("   ")
+				}
+			}
+			if node.last {
+				prefix.WriteString("└─ ")
+			} else {
+				prefix.WriteString("├─ ")
+			}
+		}
+		open = append(open[:node.depth], !node.last)
+		status := statusSymbol(node.task)
+		fmt.Fprintf(stdout, "%s%s %s\n", prefix.String(), status, node.task.Title)
+	}
+}
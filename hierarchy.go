@@ -0,0 +1,477 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Indexes tasks by their id.
+func tasksById(items []*tasks.Task) map[string]*tasks.Task {
+	byId := make(map[string]*tasks.Task)
+	for _, task := range items {
+		byId[task.Id] = task
+	}
+	return byId
+}
+
+// Reports whether the task with id is ancestorId or one of its subtasks.
+func isDescendant(byId map[string]*tasks.Task, id, ancestorId string) bool {
+	return id == ancestorId || slices.Contains(ancestors(byId, id), ancestorId)
+}
+
+// Returns the ids of the parent of a task, its grandparent and so on. Bad
+// data could have tasks be their own ancestors, or nest them endlessly, so
+// the chain ends with a warning at a cycle or after --max-depth parents.
+func ancestors(byId map[string]*tasks.Task, id string) []string {
+	var ids []string
+	seen := map[string]bool{id: true}
+	for task := byId[id]; task != nil && task.Parent != ""; task = byId[task.Parent] {
+		if seen[task.Parent] {
+			warnHierarchy("Task %s is among its own parents, ignoring the cycle", task.Parent)
+			break
+		}
+		if len(ids) == *maxDepth {
+			warnHierarchy("Task %s is nested deeper than --max-depth %d", id, *maxDepth)
+			break
+		}
+		seen[task.Parent] = true
+		ids = append(ids, task.Parent)
+	}
+	return ids
+}
+
+// Returns the ids of the tasks that have pending subtasks, at any depth.
+// Deleting those would delete the pending subtasks as well.
+func pendingParents(items []*tasks.Task) map[string]bool {
+	byId := tasksById(items)
+	parents := make(map[string]bool)
+	for _, task := range items {
+		if task.Status != "completed" {
+			for _, id := range ancestors(byId, task.Id) {
+				parents[id] = true
+			}
+		}
+	}
+	return parents
+}
+
+// Returns for each of the tasks to delete the one whose deletion deletes it:
+// its uppermost ancestor among them, or the task itself. The API deletes the
+// subtasks of a task along with it, so deleting them too would race that and
+// fail for those already gone.
+func deletionRoots(byId map[string]*tasks.Task, taskIds []string) map[string]string {
+	deleting := make(map[string]bool)
+	for _, id := range taskIds {
+		deleting[id] = true
+	}
+	roots := make(map[string]string)
+	for _, id := range taskIds {
+		roots[id] = id
+		for _, parent := range ancestors(byId, id) {
+			if deleting[parent] {
+				roots[id] = parent
+			}
+		}
+	}
+	return roots
+}
+
+// Returns the top level task a task is under, or the task itself if it is
+// at the top level.
+func rootOf(byId map[string]*tasks.Task, task *tasks.Task) *tasks.Task {
+	root := task
+	for _, id := range ancestors(byId, task.Id) {
+		if byId[id] == nil {
+			break
+		}
+		root = byId[id]
+	}
+	return root
+}
+
+// hierarchyWarnings are the warnings about malformed hierarchies given so
+// far, so that each is only given once.
+var (
+	hierarchyWarnings = make(map[string]bool)
+	hierarchyMu       sync.Mutex
+)
+
+func warnHierarchy(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	hierarchyMu.Lock()
+	defer hierarchyMu.Unlock()
+	if !hierarchyWarnings[msg] {
+		hierarchyWarnings[msg] = true
+		logger.Warn(msg)
+	}
+}
+
+// Finds the task whose title contains text, ignoring case. An exact match
+// wins over partial ones, other than that more than one match is an error.
+func findByTitle(items []*tasks.Task, text string) *tasks.Task {
+	text = strings.ToLower(strings.TrimSpace(text))
+	var matches []*tasks.Task
+	for _, task := range items {
+		title := strings.ToLower(strings.TrimSpace(task.Title))
+		if title == text {
+			return task
+		}
+		if strings.Contains(title, text) {
+			matches = append(matches, task)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		fatalf("No task matches %q", text)
+	case 1:
+		return matches[0]
+	}
+	var titles []string
+	for _, task := range matches {
+		titles = append(titles, fmt.Sprintf("%q (%s)", task.Title, task.Id))
+	}
+	fatalf("More than one task matches %q: %s", text, strings.Join(titles, ", "))
+	return nil
+}
+
+// Finds a task by its id or, if there is no task with that id, by its title
+// as findByTitle does.
+func findTask(items []*tasks.Task, idOrTitle string) *tasks.Task {
+	if task := tasksById(items)[idOrTitle]; task != nil {
+		return task
+	}
+	return findByTitle(items, idOrTitle)
+}
+
+// Returns the parent task id given by --parent, or by --parent-title looked
+// up among items. Returns an empty string if neither is given.
+func parentFromFlags(items []*tasks.Task) string {
+	if *parentId != "" && *parentTitle != "" {
+		fatalf("Only one of --parent and --parent-title can be given")
+	}
+	if *parentTitle != "" {
+		return findByTitle(items, *parentTitle).Id
+	}
+	return *parentId
+}
+
+// Makes a task a subtask of another task in the same list. Without a
+// parentId, the parent is taken from --parent or --parent-title.
+func reparent(srv *tasks.Service, tasklistId, taskId, parentId string) {
+	items := fetchTasks(srv, tasklistId)
+	if parentId == "" {
+		parentId = parentFromFlags(items)
+	}
+	byId := tasksById(items)
+	if byId[taskId] == nil {
+		fatalf("Task does not exist: %s", taskId)
+	}
+	if byId[parentId] == nil {
+		fatalf("Parent task does not exist: %s", parentId)
+	}
+	if isDescendant(byId, parentId, taskId) {
+		fatalf("Cannot move %s under %s, that would make it a subtask of itself", taskId, parentId)
+	}
+	if _, err := srv.Tasks.Move(tasklistId, taskId).Parent(parentId).Do(); err != nil {
+		fatalf("Could not move task: %v", err)
+	}
+}
+
+// Exchanges the positions of two tasks with the same parent.
+func swap(srv *tasks.Service, tasklistId, idA, idB string) {
+	if idA == "" || idB == "" {
+		usageError("Missing task ids")
+	}
+	items := fetchTasks(srv, tasklistId)
+	byId := tasksById(items)
+	for _, id := range []string{idA, idB} {
+		if byId[id] == nil {
+			fatalf("Task does not exist in this tasklist: %s", id)
+		}
+	}
+	if idA == idB {
+		return
+	}
+	parent := byId[idA].Parent
+	if byId[idB].Parent != parent {
+		fatalf("Only tasks with the same parent can be swapped, use move to put a task under another parent")
+	}
+	s := siblings(items, parent, "")
+	i := slices.IndexFunc(s, func(t *tasks.Task) bool { return t.Id == idA })
+	j := slices.IndexFunc(s, func(t *tasks.Task) bool { return t.Id == idB })
+	if i > j {
+		i, j = j, i
+	}
+	moveAfter := func(taskId, previous string) {
+		call := srv.Tasks.Move(tasklistId, taskId)
+		if parent != "" {
+			call = call.Parent(parent)
+		}
+		if previous != "" {
+			call = call.Previous(previous)
+		}
+		if _, err := call.Do(); err != nil {
+			fatalf("Could not move task: %v", err)
+		}
+	}
+	// Next to each other, moving the first after the second is enough.
+	// Otherwise the second takes the place of the first, and the first then
+	// goes after what preceded the second, which hasn't moved.
+	if j == i+1 {
+		moveAfter(s[i].Id, s[j].Id)
+		return
+	}
+	previous := ""
+	if i > 0 {
+		previous = s[i-1].Id
+	}
+	moveAfter(s[j].Id, previous)
+	moveAfter(s[i].Id, s[j-1].Id)
+}
+
+// Makes a subtask a top level task. It goes right after the top level task
+// it was under, or first with --top.
+func unparent(srv *tasks.Service, tasklistId, taskId string) {
+	if taskId == "" {
+		usageError("Missing task id")
+	}
+	byId := tasksById(fetchTasks(srv, tasklistId))
+	task := byId[taskId]
+	if task == nil {
+		fatalf("Task does not exist: %s", taskId)
+	}
+	if task.Parent == "" {
+		fatalf("Task %q is not a subtask", task.Title)
+	}
+	call := srv.Tasks.Move(tasklistId, taskId)
+	if root := rootOf(byId, task); !*top && root != task {
+		call = call.Previous(root.Id)
+	}
+	if _, err := call.Do(); err != nil {
+		fatalf("Could not move task: %v", err)
+	}
+}
+
+// Returns the tasks directly under parent, or the top level tasks if parent
+// is empty, in the order they appear in the list. The task with id except
+// is left out.
+func siblings(items []*tasks.Task, parent, except string) []*tasks.Task {
+	var result []*tasks.Task
+	for _, task := range items {
+		if task.Parent == parent && task.Id != except && !task.Deleted {
+			result = append(result, task)
+		}
+	}
+	slices.SortFunc(result, func(a, b *tasks.Task) int {
+		return strings.Compare(a.Position, b.Position)
+	})
+	return result
+}
+
+// Moves a task within its list. It stays under its current parent unless
+// --parent or --parent-title is given, and is placed after the task given by
+// --after, before the one given by --before, first with --top, last with
+// --bottom or at the 1-based --position among its siblings. With --list, it
+// goes to another list instead.
+func move(srv *tasks.Service, tasklistId, taskId, destId string) {
+	if destId != "" {
+		moveToList(srv, tasklistId, destId, taskId)
+		return
+	}
+	items := fetchTasks(srv, tasklistId)
+	task := tasksById(items)[taskId]
+	if task == nil {
+		fatalf("Task does not exist: %s", taskId)
+	}
+	parent := task.Parent
+	newParent := parentFromFlags(items)
+	if newParent != "" {
+		parent = newParent
+	}
+	previous, parent, placed := placement(items, parent, newParent != "", taskId)
+	if !placed && newParent == "" {
+		fatalf("Missing position, use --after, --before, --top, --bottom or --position")
+	}
+
+	call := srv.Tasks.Move(tasklistId, taskId)
+	if parent != "" {
+		call = call.Parent(parent)
+	}
+	if previous != "" {
+		call = call.Previous(previous)
+	}
+	if _, err := call.Do(); err != nil {
+		fatalf("Could not move task: %v", err)
+	}
+}
+
+// Returns the sibling a task goes after according to --after, --before,
+// --top, --bottom or --position, among the tasks under parent, leaving out
+// the task itself. With --before, the task goes among the siblings of that
+// task, unless the parent was given explicitly, so the parent is returned as
+// well. placed tells whether any of the flags was given.
+func placement(items []*tasks.Task, parent string, explicitParent bool, taskId string) (previous, newParent string, placed bool) {
+	placed = *after != "" || *before != "" || *top || *bottom || *position != 0
+	if *before != "" && !explicitParent {
+		next := tasksById(items)[*before]
+		if next == nil {
+			fatalf("Task does not exist: %s", *before)
+		}
+		parent = next.Parent
+	}
+	s := siblings(items, parent, taskId)
+	switch {
+	case *after != "":
+		previous = *after
+	case *before != "":
+		i := slices.IndexFunc(s, func(t *tasks.Task) bool { return t.Id == *before })
+		if i < 0 {
+			fatalf("Task %s is not a sibling of the moved task", *before)
+		}
+		// Placing it after the preceding sibling puts it right before; if
+		// there is none, the task goes to the top.
+		if i > 0 {
+			previous = s[i-1].Id
+		}
+	case *bottom:
+		if len(s) > 0 {
+			previous = s[len(s)-1].Id
+		}
+	case *position != 0:
+		if *position < 1 {
+			fatalf("Invalid position %d, positions start at 1", *position)
+		}
+		// Positions beyond the end move the task to the bottom.
+		if *position > 1 && len(s) > 0 {
+			previous = s[min(*position-1, len(s))-1].Id
+		}
+	}
+	return previous, parent, placed
+}
+
+// Moves a task with its subtasks to another list. The API can't move tasks
+// between lists, so they are copied and then deleted from the source list.
+// Title, notes, due and status are kept. In the destination, the task goes
+// to the top unless positioned with --after, --before, --top, --bottom,
+// --position, --parent or --parent-title, which refer to tasks there.
+func moveToList(srv *tasks.Service, sourceId, destId, taskId string) {
+	if sourceId == destId {
+		fatalf("Source and destination are the same tasklist")
+	}
+	source := fetchTasks(srv, sourceId)
+	byId := tasksById(source)
+	if byId[taskId] == nil {
+		fatalf("Task does not exist: %s", taskId)
+	}
+	dest := fetchTasks(srv, destId)
+	parent := parentFromFlags(dest)
+	previous, parent, _ := placement(dest, parent, parent != "", "")
+	moved, err := copySubtree(srv, destId, flatten(source), byId, taskId, parent, previous)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if err := deleteSubtree(srv, sourceId, byId, moved); err != nil {
+		fatalf("%v", err)
+	}
+}
+
+// Copies a task with its subtasks to another list, the task under parent
+// after previous and its subtasks in their order. nodes are the tasks of the
+// source list as flatten returns them. Returns the ids of the tasks copied,
+// parents first.
+func copySubtree(srv *tasks.Service, destId string, nodes []treeNode, byId map[string]*tasks.Task, taskId, parent, previous string) ([]string, error) {
+	// Subtasks follow their parent in flatten's order, so every parent is
+	// copied before its subtasks, which keep their order.
+	copies := make(map[string]string)
+	lastChild := make(map[string]string)
+	var moved []string
+	for _, node := range nodes {
+		task := node.task
+		if !isDescendant(byId, task.Id, taskId) {
+			continue
+		}
+		under, after := parent, previous
+		if task.Id != taskId {
+			under, after = copies[task.Parent], lastChild[copies[task.Parent]]
+		}
+		inserted, err := insertCopy(srv, destId, task, under, after)
+		if err != nil {
+			return moved, fmt.Errorf("Could not copy task %q to the destination: %v", task.Title, err)
+		}
+		copies[task.Id] = inserted.Id
+		lastChild[under] = inserted.Id
+		moved = append(moved, task.Id)
+	}
+	return moved, nil
+}
+
+// Deletes the tasks copySubtree copied from the source list, subtasks before
+// their parent.
+func deleteSubtree(srv *tasks.Service, sourceId string, byId map[string]*tasks.Task, moved []string) error {
+	for i := len(moved) - 1; i >= 0; i-- {
+		id := moved[i]
+		err := withBackoff(func() error { return srv.Tasks.Delete(sourceId, id).Do() })
+		if err != nil {
+			return fmt.Errorf("Copied the task, but could not delete %q from the source list: %v", byId[id].Title, err)
+		}
+	}
+	return nil
+}
+
+// treeNode is a task with its depth in the subtask hierarchy. last is set
+// for the last task among its siblings.
+type treeNode struct {
+	task  *tasks.Task
+	depth int
+	last  bool
+}
+
+// Orders tasks the way they appear in Google Tasks: siblings by position,
+// each task followed by its subtasks. Tasks whose parent is missing from
+// items are treated as top level, and so are tasks in a cycle of parents or
+// nested deeper than --max-depth, with a warning.
+func flatten(items []*tasks.Task) []treeNode {
+	byId := tasksById(items)
+	children := make(map[string][]*tasks.Task)
+	for _, task := range items {
+		parent := task.Parent
+		if byId[parent] == nil {
+			parent = ""
+		}
+		children[parent] = append(children[parent], task)
+	}
+	var nodes []treeNode
+	visited := make(map[string]bool)
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		if depth > *maxDepth {
+			return
+		}
+		s := children[parent]
+		slices.SortFunc(s, func(a, b *tasks.Task) int {
+			return strings.Compare(a.Position, b.Position)
+		})
+		for i, task := range s {
+			if visited[task.Id] {
+				continue
+			}
+			visited[task.Id] = true
+			nodes = append(nodes, treeNode{task: task, depth: depth, last: i == len(s)-1})
+			walk(task.Id, depth+1)
+		}
+	}
+	walk("", 0)
+	for _, task := range items {
+		if !visited[task.Id] {
+			warnHierarchy("Task %s is among its own parents or nested deeper than --max-depth %d, showing it at the top level", task.Id, *maxDepth)
+			visited[task.Id] = true
+			nodes = append(nodes, treeNode{task: task, depth: 0, last: true})
+			walk(task.Id, 1)
+		}
+	}
+	return nodes
+}
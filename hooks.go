@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// hookGuard is set in the environment of hooks, so that gtasks run by a hook
+// doesn't run hooks itself and end up calling itself forever.
+const hookGuard = "GTASKS_IN_HOOK"
+
+// Returns the command of a hook, given by its flag or else its environment
+// variable, like GTASKS_POST_HOOK for --post-hook.
+func hookCommand(name string) string {
+	command := *preHook
+	if name == "post-hook" {
+		command = *postHook
+	}
+	if command != "" {
+		return command
+	}
+	return os.Getenv(flagEnv(name))
+}
+
+// Runs the shell command of --pre-hook or --post-hook for a command that
+// changes tasks. The hook gets the command in GTASKS_COMMAND and its first
+// two arguments, for most commands the tasklist and the task, in
+// GTASKS_LIST and GTASKS_TASK. Its output goes to stderr, so that it
+// doesn't mix with that of gtasks. A failing hook is fatal: a failing
+// pre-hook stops the command before it changes anything.
+func runHook(name string) {
+	command := hookCommand(name)
+	if command == "" || !needsWrite(arg(0)) || *dryRun {
+		return
+	}
+	if os.Getenv(hookGuard) != "" {
+		logger.Debug("not running hook from within a hook", "hook", name)
+		return
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(),
+		hookGuard+"=1",
+		"GTASKS_HOOK="+name,
+		"GTASKS_COMMAND="+arg(0),
+		"GTASKS_LIST="+arg(1),
+		"GTASKS_TASK="+arg(2),
+	)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	logger.Debug("running hook", "hook", name, "command", command)
+	if err := cmd.Run(); err != nil {
+		if name == "pre-hook" {
+			fatalf("The pre-hook failed, nothing was changed: %v", err)
+		}
+		fatalf("The %s failed: %v", name, err)
+	}
+}
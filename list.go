@@ -0,0 +1,302 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// taskGroup holds the tasks of one tasklist for grouped output.
+type taskGroup struct {
+	title string
+	items []*tasks.Task
+}
+
+// Prints the tasks of the given tasklists. With more than one list they are
+// grouped by list if --group-by list is given, which is the default for the
+// table format.
+func list(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	updatedMin := ""
+	if *recent != "" {
+		d, err := parseDuration(*recent)
+		if err != nil {
+			fatalf("Invalid recent duration: %v", err)
+		}
+		updatedMin = currentTime().Add(-d).UTC().Format(time.RFC3339)
+	}
+	if *sinceToken != "" {
+		if updatedMin != "" {
+			fatalf("Only one of --recent and --since-token can be given")
+		}
+		if _, err := time.Parse(time.RFC3339, *sinceToken); err != nil {
+			fatalf("Invalid since token: %v", err)
+		}
+		updatedMin = *sinceToken
+	}
+	if *updSince != "" {
+		if updatedMin != "" {
+			fatalf("Only one of --recent, --since-token and --updated-since can be given")
+		}
+		if *updSince != lastRun {
+			if _, err := time.Parse(time.RFC3339, *updSince); err != nil {
+				fatalf("Invalid updated-since timestamp: %v", err)
+			}
+			updatedMin = *updSince
+		}
+	}
+
+	if *pending && *completed {
+		fatalf("Only one of --pending and --completed can be given")
+	}
+	pendingOnly, _ := statusFilter()
+	completedMin, completedMax := completedRange()
+	if *doneSince != "" {
+		if completedMin != "" || completedMax != "" {
+			fatalf("--completed-since can't be combined with --completed-on, --completed-from or --completed-to")
+		}
+		if *doneSince != lastRun {
+			if _, err := time.Parse(time.RFC3339, *doneSince); err != nil {
+				fatalf("Invalid completed-since timestamp: %v", err)
+			}
+			completedMin = *doneSince
+		}
+	}
+	keys := parseSort()
+	if pendingOnly && (completedMin != "" || completedMax != "" || *doneSince != "") {
+		fatalf("--pending or --status needsAction can't be combined with --completed-on, --completed-from, --completed-to or --completed-since")
+	}
+	// The watermarks of last-run are taken from when the tasks were fetched,
+	// so that what changes while they are printed shows up next time.
+	var runs lastRuns
+	started := time.Now().UTC().Format(time.RFC3339)
+	if *updSince == lastRun || *doneSince == lastRun {
+		runs = readLastRuns()
+	}
+	var backedUp map[string]string
+	if *sinceBackup != "" {
+		backedUp = backupUpdates(readBackup(*sinceBackup))
+	}
+	groupBy := *groupBy
+	if groupBy == "" && *all && outputFormat() == "table" {
+		groupBy = "list"
+	}
+	if groupBy != "" && groupBy != "list" && groupBy != "none" {
+		fatalf("Unknown group-by: %s", groupBy)
+	}
+	// JSON arrays and lines are printed as the pages arrive, unless the
+	// tasks have to be sorted or looked at together first.
+	var stream *jsonStream
+	if f := outputFormat(); (f == "json" || f == "jsonl") && groupBy != "list" && len(keys) == 0 &&
+		completedMin == "" && completedMax == "" && *doneSince == "" && !*withParent && !*leaves && *perList == 0 {
+		stream = newJSONStream(stdout, f == "jsonl")
+	}
+
+	var groups []taskGroup
+	shown := 0
+	watermark := *sinceToken
+	for _, tasklist := range tasklists {
+		call := srv.Tasks.List(tasklist.Id).ShowCompleted(!pendingOnly).ShowHidden(!*noHidden)
+		listUpdatedMin, listCompletedMin := updatedMin, completedMin
+		if *updSince == lastRun {
+			listUpdatedMin = runs["updated-since"][tasklist.Id]
+		}
+		if *doneSince == lastRun {
+			listCompletedMin = runs["completed-since"][tasklist.Id]
+		}
+		if listUpdatedMin != "" {
+			call = call.UpdatedMin(listUpdatedMin)
+		}
+		if *sinceToken != "" || *showDeleted {
+			call = call.ShowDeleted(true)
+		}
+		if listCompletedMin != "" {
+			call = call.CompletedMin(listCompletedMin)
+		}
+		if completedMax != "" {
+			call = call.CompletedMax(completedMax)
+		}
+		var items []*tasks.Task
+		err := forEachPage(call, func(page []*tasks.Task) {
+			for _, task := range page {
+				watermark = laterTimestamp(watermark, task.Updated)
+			}
+			if stream != nil {
+				page := changedSince(filterTasks(filterHierarchy(page)), backedUp)
+				shown += len(page)
+				stream.write(jsonTasks(page))
+			} else {
+				items = append(items, page...)
+			}
+		})
+		if err != nil {
+			fatalf("Could not list tasklist items: %v", err)
+		}
+		if stream != nil {
+			continue
+		}
+		items = changedSince(filterTasks(filterHierarchy(items)), backedUp)
+		if completedMin != "" || completedMax != "" || *doneSince != "" {
+			sortByCompleted(items)
+		}
+		sortTasks(items, keys)
+		if *perList > 0 {
+			items = items[:min(*perList, len(items))]
+		}
+		shown += len(items)
+		groups = append(groups, taskGroup{title: tasklist.Title, items: items})
+	}
+
+	if stream != nil {
+		stream.close()
+	}
+	if runs != nil {
+		for name, value := range map[string]string{"updated-since": *updSince, "completed-since": *doneSince} {
+			if value != lastRun {
+				continue
+			}
+			if runs[name] == nil {
+				runs[name] = make(map[string]string)
+			}
+			for _, tasklist := range tasklists {
+				runs[name][tasklist.Id] = started
+			}
+		}
+		writeLastRuns(runs)
+	}
+	if *sinceToken != "" {
+		// The watermark goes to stderr to keep the output itself parseable.
+		fmt.Fprintln(os.Stderr, watermark)
+	}
+
+	switch {
+	case stream != nil:
+	case groupBy == "list":
+		printGroups(groups)
+	default:
+		var items []*tasks.Task
+		for _, group := range groups {
+			items = append(items, group.items...)
+		}
+		printTasks(items)
+	}
+	failIfEmpty(shown)
+}
+
+// Exits with exitEmpty for --fail-if-empty if no tasks were shown, after
+// the output for no tasks was printed.
+func failIfEmpty(shown int) {
+	if *failEmpty && shown == 0 {
+		exit(exitEmpty)
+	}
+}
+
+// Returns the bounds for the completion time given by --completed-on, or
+// --completed-from and --completed-to, as RFC3339 timestamps. Both ends are
+// inclusive and cover whole days in the local timezone.
+func completedRange() (string, string) {
+	from, to := *doneFrom, *doneTo
+	if *doneOn != "" {
+		if from != "" || to != "" {
+			fatalf("--completed-on can't be combined with --completed-from or --completed-to")
+		}
+		from, to = *doneOn, *doneOn
+	}
+	completedMin, completedMax := "", ""
+	if from != "" {
+		completedMin = startOfDay(from, 0)
+	}
+	if to != "" {
+		completedMax = startOfDay(to, 1)
+	}
+	return completedMin, completedMax
+}
+
+// Returns the start of the given date plus days in the local timezone.
+func startOfDay(date string, days int) string {
+	due, err := parseDue(date)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	t, _ := time.Parse(time.RFC3339, due)
+	start := time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, location())
+	return start.UTC().Format(time.RFC3339)
+}
+
+// Sorts tasks by completion time, the earliest first.
+func sortByCompleted(items []*tasks.Task) {
+	slices.SortStableFunc(items, func(a, b *tasks.Task) int {
+		return strings.Compare(completedTime(a), completedTime(b))
+	})
+}
+
+func completedTime(task *tasks.Task) string {
+	if task.Completed == nil {
+		return ""
+	}
+	return *task.Completed
+}
+
+// Renders the completion time of a task for display, empty if it has none.
+func completedDisplay(task *tasks.Task) string {
+	if task.Completed == nil {
+		return ""
+	}
+	return displayTime(*task.Completed)
+}
+
+// Returns the later of two RFC3339 timestamps.
+func laterTimestamp(a, b string) string {
+	ta, errA := time.Parse(time.RFC3339, a)
+	tb, errB := time.Parse(time.RFC3339, b)
+	if errB != nil || errA == nil && !tb.After(ta) {
+		return a
+	}
+	return b
+}
+
+// lastRun is the value of --updated-since and --completed-since that stands
+// for the previous time the same flag was given for a tasklist.
+const lastRun = "last-run"
+
+// lastRuns holds the watermarks of last-run by flag name and tasklist id.
+type lastRuns map[string]map[string]string
+
+// The file last_run.json keeps the watermarks for last-run.
+func lastRunsFile() string {
+	return filepath.Join(getConfigDir(), "last_run.json")
+}
+
+// Reads the watermarks for last-run. Without any yet, none are returned, so
+// the first run shows everything.
+func readLastRuns() lastRuns {
+	runs := make(lastRuns)
+	b, err := os.ReadFile(lastRunsFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return runs
+	}
+	if err != nil {
+		fatalf("Could not read time of last run: %v", err)
+	}
+	if err := json.Unmarshal(b, &runs); err != nil {
+		fatalf("Could not parse %s: %v", lastRunsFile(), err)
+	}
+	return runs
+}
+
+func writeLastRuns(runs lastRuns) {
+	bs, err := json.Marshal(runs)
+	if err != nil {
+		fatalf("Failure when marshaling time of last run: %v", err)
+	}
+	if err := os.WriteFile(lastRunsFile(), bs, 0600); err != nil {
+		fatalf("Could not record time of last run: %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// listInfo is what lists prints for a tasklist in JSON. Tasks is only set
+// with --with-counts.
+type listInfo struct {
+	Id      string `json:"id"`
+	Title   string `json:"title"`
+	Updated string `json:"updated"`
+	Tasks   *int   `json:"tasks,omitempty"`
+}
+
+// Prints the tasklists in the format selected by --format. With
+// --with-counts, the number of tasks in each list is included, counting
+// completed and hidden ones as well; the lists are then fetched in
+// parallel.
+func lists(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	infos := make([]listInfo, len(tasklists))
+	byId := make(map[string]int)
+	var ids []string
+	for i, tasklist := range tasklists {
+		infos[i] = listInfo{Id: tasklist.Id, Title: tasklist.Title, Updated: tasklist.Updated}
+		byId[tasklist.Id] = i
+		ids = append(ids, tasklist.Id)
+	}
+	if *withCounts {
+		var mu sync.Mutex
+		results := forEachTask(ids, *concurrency, func(tasklistId string) error {
+			items, err := allTasks(srv.Tasks.List(tasklistId).ShowCompleted(true).ShowHidden(true))
+			if err != nil {
+				return err
+			}
+			n := len(items)
+			mu.Lock()
+			infos[byId[tasklistId]].Tasks = &n
+			mu.Unlock()
+			return nil
+		})
+		summarize("count tasks of", results)
+	}
+
+	switch outputFormat() {
+	case "json":
+		bs, err := json.Marshal(infos)
+		if err != nil {
+			fatalf("Failure when marshaling tasklists: %v", err)
+		}
+		fmt.Print(string(bs))
+	case "table":
+		header := []string{"ID", "TITLE", "UPDATED"}
+		if *withCounts {
+			header = append(header, "TASKS")
+		}
+		rows := [][]string{header}
+		for _, info := range infos {
+			row := []string{info.Id, info.Title, displayTime(info.Updated)}
+			if info.Tasks != nil {
+				row = append(row, strconv.Itoa(*info.Tasks))
+			}
+			rows = append(rows, row)
+		}
+		writeColumns(os.Stdout, rows, nil, -1)
+	case "plain":
+		for _, info := range infos {
+			fmt.Printf("%s\t%s\t%s", info.Id, escapePlain(info.Title), info.Updated)
+			if info.Tasks != nil {
+				fmt.Printf("\t%d", *info.Tasks)
+			}
+			fmt.Println()
+		}
+	default:
+		fatalf("Unknown format: %s", outputFormat())
+	}
+}
+
+// listDetails is what tasklist-info prints for a tasklist in JSON.
+type listDetails struct {
+	Id        string `json:"id"`
+	Title     string `json:"title"`
+	Updated   string `json:"updated"`
+	SelfLink  string `json:"selfLink"`
+	Tasks     int    `json:"tasks"`
+	Pending   int    `json:"pending"`
+	Completed int    `json:"completed"`
+}
+
+// Prints everything about a single tasklist, including how many tasks it
+// has, hidden ones included. With --format json, it is printed as a JSON
+// object instead.
+func tasklistInfo(srv *tasks.Service, tasklist *tasks.TaskList) {
+	asJSON := flagGiven("format")
+	if asJSON && *format != "json" {
+		usageError("Unknown tasklist-info format: %s, expected --format json", *format)
+	}
+	details := listDetails{
+		Id:       tasklist.Id,
+		Title:    tasklist.Title,
+		Updated:  tasklist.Updated,
+		SelfLink: tasklist.SelfLink,
+	}
+	for _, task := range fetchTasks(srv, tasklist.Id) {
+		details.Tasks++
+		if task.Status == "completed" {
+			details.Completed++
+		} else {
+			details.Pending++
+		}
+	}
+
+	if asJSON {
+		bs, err := json.Marshal(details)
+		if err != nil {
+			fatalf("Failure when marshaling tasklist: %v", err)
+		}
+		fmt.Print(string(bs))
+		return
+	}
+	field := func(name, value string) {
+		fmt.Printf("%-11s%s\n", name+":", value)
+	}
+	field("Id", details.Id)
+	field("Title", details.Title)
+	field("Updated", displayTime(details.Updated))
+	field("Link", details.SelfLink)
+	field("Tasks", fmt.Sprintf("%d (%d pending, %d completed)", details.Tasks, details.Pending, details.Completed))
+}
+
+// Deletes the tasklists that have no tasks, completed and hidden ones
+// included, after asking. The default tasklist can't be deleted and is left
+// alone.
+func pruneEmptyLists(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	defaultList, err := srv.Tasklists.Get("@default").Do()
+	if err != nil {
+		fatalf("Could not get the default tasklist: %v", err)
+	}
+	var empty []*tasks.TaskList
+	for _, tasklist := range tasklists {
+		if tasklist.Id == defaultList.Id {
+			continue
+		}
+		if len(fetchTasks(srv, tasklist.Id)) == 0 {
+			empty = append(empty, tasklist)
+		}
+	}
+	if len(empty) == 0 {
+		fmt.Println("No empty tasklists")
+		return
+	}
+	if *dryRun {
+		for _, tasklist := range empty {
+			fmt.Printf("Would delete %s\n", tasklist.Title)
+		}
+		return
+	}
+	var titles []string
+	for _, tasklist := range empty {
+		titles = append(titles, tasklist.Title)
+	}
+	if !confirmBulk(fmt.Sprintf("Delete %d empty tasklists: %s?", len(empty), strings.Join(titles, ", ")), len(empty)) {
+		return
+	}
+	for _, tasklist := range empty {
+		err := withBackoff(func() error {
+			return srv.Tasklists.Delete(tasklist.Id).Do()
+		})
+		forgetTasklists()
+		if err != nil {
+			fatalf("Could not delete tasklist %s: %v", tasklist.Title, err)
+		}
+		fmt.Printf("Deleted %s\n", tasklist.Title)
+	}
+}
+
+// Renames a tasklist. Renaming it to the title of another tasklist is
+// refused, as tasklists are told apart by title.
+func renameList(srv *tasks.Service, tasklists []*tasks.TaskList, tasklistId, title string) {
+	if title == "" {
+		usageError("Missing new title")
+	}
+	for _, tasklist := range tasklists {
+		if tasklist.Title == title && tasklist.Id != tasklistId {
+			fatalf("A tasklist called %q already exists", title)
+		}
+	}
+	if *dryRun {
+		fmt.Printf("Would rename to %s\n", title)
+		return
+	}
+	patchTasklistTitle(srv, tasklistId, title)
+}
+
+// Renames the tasklists by a substitution like sed's, "s/pattern/replacement/",
+// with the regexp syntax of Go. Only the first match in a title is replaced
+// unless the g flag follows. In the replacement, $1 or \1 stand for the
+// first group and so on. Only the selected tasklists are renamed, but
+// renames that would give any two tasklists the same title are skipped.
+func renameLists(srv *tasks.Service, tasklists, selected []*tasks.TaskList, expr string) {
+	pattern, replacement, global := parseSubstitution(expr)
+	titles := make(map[string]string)
+	for _, tasklist := range selected {
+		src := tasklist.Title
+		var title string
+		if global {
+			title = pattern.ReplaceAllString(src, replacement)
+		} else if m := pattern.FindStringSubmatchIndex(src); m != nil {
+			title = src[:m[0]] + string(pattern.ExpandString(nil, replacement, src, m)) + src[m[1]:]
+		}
+		if title != "" && title != src {
+			titles[tasklist.Id] = title
+		}
+	}
+
+	// Skipping a rename keeps a title in use, which can make another rename
+	// clash with it, so this goes on until nothing more is skipped.
+	skipped := make(map[string]bool)
+	for changed := true; changed; {
+		changed = false
+		taken := make(map[string]int)
+		for _, tasklist := range tasklists {
+			title, ok := titles[tasklist.Id]
+			if !ok || skipped[tasklist.Id] {
+				title = tasklist.Title
+			}
+			taken[title]++
+		}
+		for id, title := range titles {
+			if !skipped[id] && taken[title] > 1 {
+				skipped[id] = true
+				changed = true
+			}
+		}
+	}
+
+	var renames []*tasks.TaskList
+	for _, tasklist := range selected {
+		title, ok := titles[tasklist.Id]
+		switch {
+		case !ok:
+		case skipped[tasklist.Id]:
+			fmt.Fprintf(os.Stderr, "Skipping %s, there would be more than one tasklist called %q\n", tasklist.Title, title)
+		default:
+			renames = append(renames, tasklist)
+		}
+	}
+	if len(renames) == 0 {
+		fmt.Println("Nothing to rename")
+		return
+	}
+	if *dryRun {
+		for _, tasklist := range renames {
+			fmt.Printf("Would rename %s to %s\n", tasklist.Title, titles[tasklist.Id])
+		}
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("Rename %d tasklists?", len(renames)), len(renames)) {
+		return
+	}
+	for _, tasklist := range renames {
+		patchTasklistTitle(srv, tasklist.Id, titles[tasklist.Id])
+		fmt.Printf("Renamed %s to %s\n", tasklist.Title, titles[tasklist.Id])
+	}
+}
+
+// groupReference is a reference to a group like sed's \1, replaced by the
+// ${1} of regexp.
+var groupReference = regexp.MustCompile(`\\(\d)`)
+
+// Parses a substitution like "s/pattern/replacement/g". Any character
+// following the s delimits the parts, as in sed.
+func parseSubstitution(expr string) (*regexp.Regexp, string, bool) {
+	if len(expr) < 2 || expr[0] != 's' {
+		usageError("Invalid substitution %q, expected s/pattern/replacement/", expr)
+	}
+	delim := expr[1:2]
+	parts := strings.Split(expr[2:], delim)
+	if len(parts) != 3 || parts[2] != "" && parts[2] != "g" {
+		usageError("Invalid substitution %q, expected s/pattern/replacement/", expr)
+	}
+	pattern, err := regexp.Compile(parts[0])
+	if err != nil {
+		usageError("Invalid regular expression: %v", err)
+	}
+	return pattern, groupReference.ReplaceAllString(parts[1], "$${$1}"), parts[2] == "g"
+}
+
+func patchTasklistTitle(srv *tasks.Service, tasklistId, title string) {
+	err := withBackoff(func() error {
+		_, err := srv.Tasklists.Patch(tasklistId, &tasks.TaskList{Title: title}).Do()
+		return err
+	})
+	forgetTasklists()
+	if err != nil {
+		fatalf("Could not rename tasklist to %s: %v", title, err)
+	}
+}
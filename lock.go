@@ -0,0 +1,54 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+const (
+	// How long to wait for another process to release a lock.
+	lockTimeout = 10 * time.Second
+	// Locks older than this are taken to be left over from a process that
+	// crashed, and are broken.
+	lockStale = 5 * time.Minute
+)
+
+// Takes a lock on file by creating file.lock next to it, waiting for other
+// processes holding it to finish. Works the same on every platform, unlike
+// flock. The returned function releases the lock, it is also released when
+// exiting.
+func lockFile(file string) func() {
+	lock := file + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			fatalf("Could not lock %s: %v", file, err)
+		}
+		if info, err := os.Stat(lock); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			fatalf("%s is locked by another gtasks process. If there is none, delete %s.", file, lock)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	released := false
+	unlock := func() {
+		if !released {
+			released = true
+			os.Remove(lock)
+		}
+	}
+	exitHooks = append(exitHooks, unlock)
+	return unlock
+}
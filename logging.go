@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// logger writes diagnostics to stderr. It is silent except for warnings
+// unless --verbose or --debug is given.
+var logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func setupLogging() {
+	level := slog.LevelWarn
+	if *verbose {
+		level = slog.LevelInfo
+	}
+	if *debug {
+		level = slog.LevelDebug
+	}
+	logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// logTransport logs every request going through it. At debug level the
+// headers and bodies of requests and responses are logged as well, except
+// for the authorization header and the bodies of OAuth token requests,
+// which hold secrets.
+type logTransport struct {
+	next http.RoundTripper
+}
+
+func (t *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attrs := []any{"method", req.Method, "url", req.URL.Host + req.URL.Path}
+	if list, task := apiTarget(req.URL.Path); list != "" {
+		attrs = append(attrs, "list", list)
+		if task != "" {
+			attrs = append(attrs, "task", task)
+		}
+	}
+	logger.Info("request", attrs...)
+	debug := logger.Enabled(req.Context(), slog.LevelDebug) && req.URL.Host != "oauth2.googleapis.com"
+	if debug {
+		body := ""
+		if req.GetBody != nil {
+			if rc, err := req.GetBody(); err == nil {
+				b, _ := io.ReadAll(rc)
+				rc.Close()
+				body = string(b)
+			}
+		}
+		logger.Debug("request details", "url", req.URL.String(), "header", redact(req.Header), "body", body)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		logger.Info("request failed", "error", err)
+		return nil, err
+	}
+	logger.Info("response", "status", resp.StatusCode, "duration", time.Since(start).Round(time.Millisecond))
+	if debug {
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		logger.Debug("response details", "header", resp.Header, "body", string(b))
+	}
+	return resp, nil
+}
+
+// Extracts the tasklist and task ids from a Tasks API path like
+// /tasks/v1/lists/{list}/tasks/{task}.
+func apiTarget(path string) (list, task string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "lists":
+			list = parts[i+1]
+		case "tasks":
+			if list != "" {
+				task = parts[i+1]
+			}
+		}
+	}
+	return list, task
+}
+
+func redact(header http.Header) http.Header {
+	header = header.Clone()
+	if header.Get("Authorization") != "" {
+		header.Set("Authorization", "REDACTED")
+	}
+	return header
+}
@@ -3,12 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -16,162 +23,854 @@ import (
 	"google.golang.org/api/tasks/v1"
 )
 
+var (
+	accessToken = flag.Bool("access", false, "print-token: print the access token")
+	apiStats    = flag.Bool("api-stats", false, "print the number of API calls and bytes transferred when done")
+	after       = flag.String("after", "", "move: place the task after this sibling task")
+	all         = flag.Bool("all", false, "archive, calendar, find-duplicates, list, pick: operate on every tasklist")
+	appendTasks = flag.Bool("append", false, "add: add the tasks below the last one instead of at the top")
+	auditLog    = flag.String("audit-log", "", "append a JSON line for every change made to this file")
+	authPort    = flag.Int("auth-port", 0, "authorize through a redirect to this localhost port instead of copying the code")
+	before      = flag.String("before", "", "move: place the task before this sibling task")
+	bottom      = flag.Bool("bottom", false, "move: place the task last among its siblings")
+	cancel      = flag.String("cancel", "", "remind: cancel the reminder of this task")
+	sinceBackup = flag.String("changed-since-backup", "", "list: only show tasks added or modified since this backup was made")
+	clearSource = flag.Bool("clear", false, "move-completed: delete the tasks from the source list once copied")
+	authCode    = flag.String("code", "", "auth: the authorization code to exchange for a token")
+	completed   = flag.Bool("completed", false, "list: only show completed tasks")
+	doneFrom    = flag.String("completed-from", "", "list: only show tasks completed on or after this date")
+	doneOn      = flag.String("completed-on", "", "list: only show tasks completed on this date")
+	doneSince   = flag.String("completed-since", "", "list: only show tasks completed after this RFC3339 timestamp, or \"last-run\" for the previous time this was given")
+	doneSymbol  = flag.String("completed-symbol", "[x]", "table, tree, tui: the marker of completed tasks, e.g. ✓")
+	doneTo      = flag.String("completed-to", "", "list: only show tasks completed on or before this date")
+	concurrency = flag.Int("concurrency", 5, "number of parallel API calls in bulk operations")
+	maxUnasked  = flag.Int("confirm-count", 10, "ask for confirmation before bulk operations on more than this many tasks")
+	cpuprofile  = flag.String("cpuprofile", "", "write a CPU profile to this file")
+	createList  = flag.Bool("create-list", false, "add, import-markdown, move-completed, move-all: create the target tasklist if it doesn't exist")
+	dateFormat  = flag.String("date-format", "iso", "how dates are shown: iso, us, eu or a Go time layout")
+	debug       = flag.Bool("debug", false, "log API requests and responses in detail to stderr")
+	dedupeBy    = flag.String("dedupe-by", "none", "import-markdown: skip tasks already in the list with the same title, title+due or none")
+	deleteExtra = flag.Bool("delete-extra", false, "find-duplicates: delete all but the oldest task of each group")
+	dialTimeout = flag.Duration("dial-timeout", 10*time.Second, "timeout for establishing a connection")
+	dryRun      = flag.Bool("dry-run", false, "only print what would be done")
+	addDue      = flag.String("due", "", "add: the due date of the tasks")
+	dueBefore   = flag.String("due-before", "", "list, pick, move-all: only consider tasks due before this date")
+	escapeNL    = flag.Bool("escape-newlines", false, "export --format csv: escape newlines in titles and notes like the plain format does")
+	failEmpty   = flag.Bool("fail-if-empty", false, "list, search: exit with code 3 if no tasks match")
+	flat        = flag.Bool("flat", false, "list: print subtasks like other tasks, with the title of their parent, even with --tree")
+	format      = flag.String("format", "json", "list: output format, json, jsonl, table, plain or count; export: todoist, csv or html")
+	groupBy     = flag.String("group-by", "", "list: with --all, group the output by \"list\" or \"none\"; default is list for the table format")
+	understood  = flag.Bool("i-understand", false, "print-token: confirm that the token is a secret to handle with care")
+	ifAbsent    = flag.Bool("if-absent", false, "add: don't add the task if a pending one with the same title exists")
+	searchIn    = flag.String("in", "both", "search: where to search, title, notes or both")
+	inclDone    = flag.Bool("include-completed", false, "add, import-markdown: with --if-absent or --dedupe-by, compare with completed tasks as well")
+	withParent  = flag.Bool("include-parent-title", false, "list, export --format csv: add the title of the parent of subtasks to the output")
+	jsonErrors  = flag.Bool("json-errors", false, "print errors as JSON objects to stderr")
+	leaves      = flag.Bool("leaves", false, "list: only show tasks without subtasks")
+	perList     = flag.Int("limit-per-list", 0, "list: with --all, show at most this many tasks of each list, after filtering and sorting")
+	destList    = flag.String("list", "", "move: move the task to this tasklist, with its subtasks")
+	listTTL     = flag.Duration("list-cache-ttl", 5*time.Minute, "how long to use the cached tasklists before fetching them again, 0 to always fetch")
+	listFilter  = flag.String("list-filter", "", "with --all and for backup: only the tasklists whose title matches this glob, e.g. \"Work*\"")
+	listMap     = flag.String("list-map", "", "replay: comma separated old=new pairs mapping tasklist ids in the log to tasklists")
+	maxDepth    = flag.Int("max-depth", 50, "how many levels of subtasks to follow at most, against malformed data")
+	maxInflight = flag.Int("max-inflight", 10, "the most API calls in flight at once across all operations, 0 for no limit")
+	noBrowser   = flag.Bool("no-browser", false, "don't open the browser when authorizing")
+	noHidden    = flag.Bool("no-hidden", false, "list: leave out completed tasks hidden by clearing the list")
+	noLinks     = flag.Bool("no-links", false, "table: don't make titles clickable links to Google Tasks")
+	noUnicode   = flag.Bool("no-unicode", false, "list: with --tree, indent with spaces instead of drawing lines")
+	addNotes    = flag.String("notes", "", "add: the notes of the tasks")
+	notesFile   = flag.String("notes-from-file", "", "add: read the notes of the tasks from this file")
+	notesTmpl   = flag.String("notes-template", "", "add: the notes of tasks added without --notes, {{date}} and {{list}} are replaced by today's date and the tasklist")
+	notesOnly   = flag.Bool("notes-only", false, "list, search: only show tasks that have notes")
+	notesWidth  = flag.Int("notes-width", 40, "table: maximum width of the notes column")
+	fixedNow    = flag.String("now", "", "use this RFC3339 timestamp as the current time for relative dates")
+	number      = flag.Bool("number", false, "add: number the titles, like \"1. Title\"")
+	numberStart = flag.Int("number-start", 1, "add: with --number, the number of the first title")
+	outputDir   = flag.String("output-dir", "", "backup: with --split, the directory to write the files to, default the current one")
+	perPage     = flag.Int("page-size", maxPageSize, "number of items fetched per API call, at most 100")
+	parentId    = flag.String("parent", "", "add, move, reparent: make the task a subtask of this task")
+	parentTitle = flag.String("parent-title", "", "add, move, reparent: like --parent, but find the parent by its title")
+	overallTime = flag.Duration("overall-timeout", 0, "time limit for the whole command, 0 for none")
+	pending     = flag.Bool("pending", false, "list: only show pending tasks")
+	todoSymbol  = flag.String("pending-symbol", "[ ]", "table, tree, tui: the marker of pending tasks, e.g. ·")
+	plain       = flag.Bool("plain", false, "list: short for --format plain")
+	position    = flag.Int("position", 0, "move: place the task at this position among its siblings, starting at 1")
+	postHook    = flag.String("post-hook", "", "shell command to run after a command changed tasks, also GTASKS_POST_HOOK")
+	preHook     = flag.String("pre-hook", "", "shell command to run before a command changes tasks, also GTASKS_PRE_HOOK")
+	prefix      = flag.String("prefix", "", "add: put this in front of every title")
+	proxy       = flag.String("proxy", "", "proxy URL for all requests, overrides HTTP_PROXY and HTTPS_PROXY")
+	quiet       = flag.Bool("quiet", false, "backup: don't report progress; print-token: leave out the warning")
+	readonly    = flag.Bool("readonly", false, "only ask for read access when authorizing")
+	recent      = flag.String("recent", "", "list: only show tasks updated within this duration, e.g. 12h or 3d")
+	refresh     = flag.Bool("refresh", false, "fetch the tasklists even if the cached ones are recent enough")
+	regex       = flag.Bool("regex", false, "search: the text is a regular expression; rename-list: rename tasklists by a substitution like sed's")
+	relDates    = flag.Bool("relative-dates", false, "list, show: show dues relative to today, like tomorrow or in 5d")
+	requireAcct = flag.String("require-account", "", "only make changes if the token belongs to this email address")
+	respTimeout = flag.Duration("response-timeout", 30*time.Second, "timeout for waiting on a response, 0 for none")
+	showAge     = flag.Bool("show-age", false, "list: add a column with the time since each task was last modified")
+	showDone    = flag.Bool("show-completion", false, "list: add the completion time of tasks to the table and plain output")
+	showDeleted = flag.Bool("show-deleted", false, "list: include deleted tasks")
+	since       = flag.String("since", "", "backup: only include tasks updated after this RFC3339 timestamp, or \"last\" for the previous backup")
+	sinceToken  = flag.String("since-token", "", "list: only show tasks updated since this RFC3339 timestamp, including deleted ones, and print the latest update to stderr")
+	sortBy      = flag.String("sort", "", "list, search: sort by these comma separated keys: due, title, status, updated, completed, position")
+	sortDir     = flag.String("sort-dir", "", "list, search: comma separated asc or desc for each --sort key, asc by default")
+	split       = flag.Bool("split", false, "backup: write one file per tasklist and a manifest.json")
+	taskStatus  = flag.String("status", "all", "list: only show tasks with this status, needsAction, completed or all")
+	strict      = flag.Bool("strict", false, "stop bulk operations at the first failure")
+	tag         = flag.String("tag", "", "list, pick, move-all: only consider tasks tagged with this #tag")
+	tmplFile    = flag.String("template-file", "", "list: print every task with the Go text/template in this file")
+	thenList    = flag.Bool("then-list", false, "list the tasklist after changing it")
+	timeFormat  = flag.String("time-format", "24h", "how times are shown: 24h, 12h or a Go time layout")
+	top         = flag.Bool("top", false, "move: place the task first among its siblings; unparent: place the task first in the list")
+	topLevel    = flag.Bool("top-level", false, "list: only show tasks that aren't subtasks")
+	traceFile   = flag.String("trace", "", "write an execution trace to this file")
+	tree        = flag.Bool("tree", false, "list: print the tasks as a tree of subtasks")
+	uncheckAll  = flag.Bool("uncheck-all", false, "check-all: mark all completed tasks as pending instead")
+	updSince    = flag.String("updated-since", "", "list: only show tasks updated after this RFC3339 timestamp, or \"last-run\" for the previous time this was given")
+	userAgent   = flag.String("user-agent", "", "the User-Agent header of requests, gtasks/<version> by default")
+	utc         = flag.Bool("utc", false, "interpret dates like \"tomorrow\" or 2024-06-01 in UTC instead of the local timezone")
+	verbose     = flag.Bool("verbose", false, "log every API request to stderr")
+	week        = flag.Bool("week", false, "calendar: show the current week instead of the month")
+	withCounts  = flag.Bool("with-counts", false, "lists: include the number of tasks of every list")
+	wrap        = flag.Bool("wrap", false, "table: wrap notes instead of truncating them")
+	yes         = flag.Bool("yes", false, "don't ask for confirmation")
+)
+
+const usageText = `Usage: gtasks <command> [arguments] [flags]
+
+Commands:
+  add <tasklist> <title>... [--notes <text>] [--due <date>] [--number [--number-start <n>]] [--prefix <text>] [--append] [--parent <taskId>|--parent-title <text>] [--if-absent]
+  lists [--with-counts]
+  tasklist-info <tasklist> [--format json]
+  list <tasklist> | list --all
+  check <tasklist> <taskId>
+  uncheck <tasklist> <taskId>
+  reopen <tasklist> <taskId>
+  snooze <tasklist> <taskId> <duration>
+  show <tasklist> <taskId|title>
+  describe <tasklist> <taskId|title>
+  cat <tasklist> <taskId|title>
+  sort <tasklist> --by <keys> [--sort-dir <dirs>]
+  rename <tasklist> <taskId|title> <newTitle>
+  touch <tasklist> <taskId|title>
+  rename-list <tasklist> <newTitle> | rename-list --regex s/<pattern>/<replacement>/[g]
+  check-all <tasklist> [--uncheck-all]
+  delete <tasklist> <taskId>...
+  empty <tasklist>
+  purge <tasklist> | purge --all
+  prune-empty-lists
+  move <tasklist> <taskId> [--after <taskId>|--before <taskId>|--top|--bottom|--position <n>] [--parent <taskId>] [--list <tasklist>]
+  move-completed <source> <destination> [--clear]
+  move-all <source> <destination> [--tag <tag>] [--due-before <date>] [--pending|--completed]
+  reparent <tasklist> <taskId> <parentId>|--parent-title <text>
+  unparent <tasklist> <taskId> [--top]
+  swap <tasklist> <taskId> <taskId>
+  find-duplicates <tasklist> | find-duplicates --all
+  import-markdown <file> <tasklist>
+  pick <tasklist> | pick --all
+  first <tasklist>
+  search <tasklist> <text> | search --all <text> [--in title|notes|both] [--regex]
+  tui [tasklist]
+  remind <tasklist> <taskId> <when> | remind | remind --cancel <taskId>
+  batch <file>
+  replay <file> [--list-map <old=new,...>]
+  backup <file> | backup --split [--output-dir <dir>]
+  archive <tasklist> <file> | archive --all <file>
+  calendar <tasklist> | calendar --all
+  export <tasklist> <file> | export --all <file> --format todoist|csv|html
+  diff <old.json> <new.json> [--format json]
+  doctor
+  auth [--code <code>]
+  whoami
+  print-token --access --i-understand [--quiet]
+
+Flags:
+`
+
+// hiddenFlags are left out of the usage, they are meant for developers.
+var hiddenFlags = map[string]bool{"cpuprofile": true, "now": true, "trace": true}
+
+func init() {
+	flag.BoolVar(verbose, "v", false, "short for --verbose")
+	flag.StringVar(sortBy, "by", "", "short for --sort")
+}
+
+func usage() {
+	fmt.Fprint(flag.CommandLine.Output(), usageText)
+	visible := flag.NewFlagSet("", flag.ContinueOnError)
+	visible.SetOutput(flag.CommandLine.Output())
+	flag.VisitAll(func(f *flag.Flag) {
+		if !hiddenFlags[f.Name] {
+			visible.Var(f.Value, f.Name, f.Usage)
+			visible.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	visible.PrintDefaults()
+}
+
+// exitHooks are run before the process exits, also on fatal errors.
+var exitHooks []func()
+
+// Runs the exit hooks, most recently added first, and exits. Within a batch
+// only the current line is ended.
+func exit(code int) {
+	if code != 0 && interrupted.Err() != nil {
+		code = exitInterrupted
+		if timedOut() {
+			code = exitTimedOut
+		}
+	}
+	if inBatch {
+		panic(batchFailure{code: code})
+	}
+	runExitHooks()
+	os.Exit(code)
+}
+
+func runExitHooks() {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+}
+
+// Like log.Fatalf, but runs the exit hooks before exiting. Network errors
+// are replaced by advice on what to check, the error itself is only shown
+// with --verbose.
+func fatalf(format string, v ...any) {
+	msg := fmt.Sprintf(format, v...)
+	if advice := networkAdvice(v...); advice != "" {
+		if *verbose {
+			msg += "\n" + advice
+		} else {
+			msg = advice + " (--verbose shows the error)"
+		}
+	}
+	if inBatch {
+		panic(batchFailure{msg: msg, code: exitFailure})
+	}
+	if *jsonErrors {
+		printJSONError(msg, exitFailure, v...)
+	} else {
+		log.Print(msg)
+	}
+	exit(exitFailure)
+}
+
+// args holds the positional arguments left over after flag parsing.
+var args []string
+
+// given holds the names of the flags given on the command line and, in a
+// batch, on the current line.
+var given = make(map[string]bool)
+
+// Parses command line arguments, allowing flags to appear between and after
+// the positional arguments. The flags are parsed by a flag set of their own,
+// so that the flags given can be told apart from those a batch reset. Invalid
+// flags are returned as errors, without printing anything, for the caller to
+// report.
+func parseArgs(arguments []string) error {
+	args = nil
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.Usage = func() {}
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	defer fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if err := fs.Parse(arguments); err != nil {
+		return err
+	}
+	rest := fs.Args()
+	for len(rest) > 0 {
+		args = append(args, rest[0])
+		if err := fs.Parse(rest[1:]); err != nil {
+			return err
+		}
+		rest = fs.Args()
+	}
+	return nil
+}
+
+// Reports whether the flag was given on the command line, or on the current
+// line of a batch.
+func flagGiven(name string) bool {
+	return given[name]
+}
+
+// envFlags are the flags that can also be set once and for all through an
+// environment variable named after them, like GTASKS_AUTH_PORT for
+// --auth-port. A flag given on the command line takes precedence.
+var envFlags = []string{"append", "auth-port", "list-cache-ttl", "notes-template", "user-agent"}
+
+// Returns the name of the environment variable for a flag.
+func flagEnv(name string) string {
+	return "GTASKS_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// Sets the flags of envFlags not given on the command line from their
+// environment variables.
+func applyEnvFlags() {
+	for _, name := range envFlags {
+		value := os.Getenv(flagEnv(name))
+		if value == "" || flagGiven(name) {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			usageError("Invalid %s: %v", flagEnv(name), err)
+		}
+	}
+}
+
+// Returns the i'th positional argument, or an empty string if there is none.
+func arg(i int) string {
+	if i < 0 || i >= len(args) {
+		return ""
+	}
+	return args[i]
+}
+
 func getConfigDir() string {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
-		log.Fatalf("Could not get current user: %v", err)
+		fatalf("Could not get current user: %v", err)
 	}
 	return filepath.Join(configDir, "gtasks")
 }
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(config *oauth2.Config) *http.Client {
+// storedToken is what token.json holds: the OAuth token and, if known, the
+// scopes that were granted with it.
+type storedToken struct {
+	oauth2.Token
+	Scope string `json:"scope,omitempty"`
+}
+
+// Retrieve a token, saves the token, then returns the generated client and
+// the scopes granted to it.
+func getClient(ctx context.Context, config *oauth2.Config) (*http.Client, string) {
 	// The file token.json stores the user's access and refresh tokens, and is
 	// created automatically when the authorization flow completes for the first
 	// time.
+	// GTASKS_TOKEN can hold the token instead, for environments without a
+	// persistent config directory.
+	if env := os.Getenv("GTASKS_TOKEN"); env != "" {
+		tok := &storedToken{}
+		if err := json.Unmarshal([]byte(env), tok); err != nil {
+			fatalf("Unable to parse GTASKS_TOKEN: %v", err)
+		}
+		return config.Client(ctx, &tok.Token), tok.Scope
+	}
 	tokFile := filepath.Join(getConfigDir(), "token.json")
+	// Other gtasks processes wait while the token is being read or written.
+	// The lock isn't held while waiting for the user to authorize, which
+	// would take longer than they wait.
+	unlock := lockFile(tokFile)
 	tok, err := tokenFromFile(tokFile)
+	unlock()
 	if err != nil {
-		tok = getTokenFromWeb(config)
+		tok = getTokenFromWeb(ctx, config)
+		unlock = lockFile(tokFile)
 		saveToken(tokFile, tok)
+		unlock()
 	}
-	return config.Client(context.Background(), tok)
+	return config.Client(ctx, &tok.Token), tok.Scope
 }
 
 // Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config) *storedToken {
+	if *authPort != 0 {
+		return tokenFromRedirect(ctx, config, *authPort)
+	}
 	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
 	fmt.Printf("Go to the following link in your browser then type the "+
 		"authorization code: \n%v\n", authURL)
+	if !*noBrowser {
+		openBrowser(authURL)
+	}
 
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Fatalf("Unable to read authorization code: %v", err)
+	var code string
+	if _, err := fmt.Scan(&code); err != nil {
+		fatalf("Unable to read authorization code: %v", err)
 	}
+	return exchangeCode(ctx, config, code)
+}
 
-	tok, err := config.Exchange(context.TODO(), authCode)
+// Exchanges an authorization code for a token.
+func exchangeCode(ctx context.Context, config *oauth2.Config, code string) *storedToken {
+	tok, err := config.Exchange(ctx, strings.TrimSpace(code))
 	if err != nil {
-		log.Fatalf("Unable to retrieve token from web: %v", err)
+		fatalf("Unable to retrieve token from web: %v", err)
 	}
-	return tok
+	scope, _ := tok.Extra("scope").(string)
+	return &storedToken{Token: *tok, Scope: scope}
+}
+
+// Authorizes gtasks and saves the token, without running a command. With
+// --code the given authorization code is used, otherwise the code is asked
+// for like on first use, which also reads it from stdin when piped.
+func authorize(ctx context.Context, config *oauth2.Config) {
+	var tok *storedToken
+	if *authCode != "" {
+		tok = exchangeCode(ctx, config, *authCode)
+	} else {
+		tok = getTokenFromWeb(ctx, config)
+	}
+	tokFile := filepath.Join(getConfigDir(), "token.json")
+	defer lockFile(tokFile)()
+	saveToken(tokFile, tok)
 }
 
 // Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
+func tokenFromFile(file string) (*storedToken, error) {
 	f, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	tok := &oauth2.Token{}
+	tok := &storedToken{}
 	err = json.NewDecoder(f).Decode(tok)
 	return tok, err
 }
 
 // Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
+func saveToken(path string, token *storedToken) {
 	fmt.Printf("Saving credential file to: %s\n", path)
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		fatalf("Unable to cache oauth token: %v", err)
 	}
 	defer f.Close()
 	json.NewEncoder(f).Encode(token)
 }
 
+// readCommands don't modify anything and work with read-only access.
+var readCommands = map[string]bool{
+	"backup":          true,
+	"calendar":        true,
+	"cat":             true,
+	"describe":        true,
+	"export":          true,
+	"find-duplicates": true,
+	"first":           true,
+	"list":            true,
+	"lists":           true,
+	"notify":          true,
+	"pick":            true,
+	"remind":          true,
+	"search":          true,
+	"show":            true,
+	"tasklist-info":   true,
+}
+
+// Reports whether the command is going to modify tasks or tasklists.
+func needsWrite(cmd string) bool {
+	if cmd == "find-duplicates" {
+		return *deleteExtra
+	}
+	return !readCommands[cmd]
+}
+
+// Reports whether the granted scopes only allow reading tasks. Tokens saved
+// without scope information are assumed to have full access.
+func isReadOnly(scope string) bool {
+	scopes := strings.Fields(scope)
+	return slices.Contains(scopes, tasks.TasksReadonlyScope) && !slices.Contains(scopes, tasks.TasksScope)
+}
+
+// Returns the task titled like title, ignoring case, or nil if there is none.
+// Only pending tasks are considered, unless --include-completed is given.
+func findExisting(items []*tasks.Task, title string) *tasks.Task {
+	for _, task := range items {
+		if task.Status == "completed" && !*inclDone {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(task.Title), strings.TrimSpace(title)) {
+			return task
+		}
+	}
+	return nil
+}
+
+// Looks up the id of the named tasklist, exiting if there is no such list.
+// The error suggests the closest title in case of a typo. Without a name,
+// the only tasklist there is is used, or the user is asked to pick one.
+func getTasklistId(tasklistIds map[string]string, name string) string {
+	if name == "" {
+		name = chooseTasklist(tasklistIds)
+	}
+	tasklistId := tasklistIds[name]
+	if tasklistId == "" {
+		var titles []string
+		for title := range tasklistIds {
+			titles = append(titles, title)
+		}
+		slices.Sort(titles)
+		if suggestion := closest(name, titles); suggestion != "" {
+			fatalf("Tasklist '%s' does not exist. Did you mean '%s'?", name, suggestion)
+		}
+		fatalf("Tasklist does not exist: %s", name)
+	}
+	return tasklistId
+}
+
+// Returns the title of the tasklist with the given id.
+func tasklistTitle(tasklistIds map[string]string, tasklistId string) string {
+	for title, id := range tasklistIds {
+		if id == tasklistId {
+			return title
+		}
+	}
+	return tasklistId
+}
+
+// Returns every tasklist with --all, otherwise the one named by the i'th
+// positional argument. The second result is the index of the positional
+// argument following the tasklist, if any.
+func selectTasklists(tasklists []*tasks.TaskList, tasklistIds map[string]string, i int) ([]*tasks.TaskList, int) {
+	if *all {
+		return filterTasklists(tasklists), i
+	}
+	tasklistId := getTasklistId(tasklistIds, arg(i))
+	for _, tasklist := range tasklists {
+		if tasklist.Id == tasklistId {
+			return []*tasks.TaskList{tasklist}, i + 1
+		}
+	}
+	return nil, i + 1
+}
+
+// Keeps the tasklists whose title matches --list-filter, a glob pattern
+// as understood by path.Match.
+func filterTasklists(tasklists []*tasks.TaskList) []*tasks.TaskList {
+	if *listFilter == "" {
+		return tasklists
+	}
+	var result []*tasks.TaskList
+	for _, tasklist := range tasklists {
+		ok, err := path.Match(*listFilter, tasklist.Title)
+		if err != nil {
+			usageError("Invalid list filter %q: %v", *listFilter, err)
+		}
+		if ok {
+			result = append(result, tasklist)
+		}
+	}
+	return result
+}
+
 func main() {
-	ctx := context.Background()
+	flag.Usage = usage
+	if err := parseArgs(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			flag.Usage()
+			exit(0)
+		}
+		// Parsing stops at the invalid flag, which may come before
+		// --json-errors.
+		if slices.Contains(os.Args[1:], "--json-errors") || slices.Contains(os.Args[1:], "-json-errors") {
+			*jsonErrors = true
+		}
+		usageError("%v", err)
+	}
+	applyEnvFlags()
+	cmd := arg(0)
+	if cmd == "" {
+		if !*jsonErrors {
+			flag.Usage()
+			exit(exitUsage)
+		}
+		usageError("Missing command")
+	}
+	setupLogging()
+	startProfiling()
+	handleSignals()
+	if *tmplFile != "" {
+		outputTemplate()
+	}
+
+	// The OAuth library picks up the HTTP client to use from the context.
+	ctx := context.WithValue(interrupted, oauth2.HTTPClient,
+		&http.Client{Transport: newTransport()})
+	if cmd == "diff" {
+		diff(arg(1), arg(2))
+		exit(0)
+	}
+	if cmd == "config" {
+		showConfig()
+		exit(0)
+	}
+	if cmd == "doctor" {
+		doctor(ctx)
+		exit(0)
+	}
 	b, err := os.ReadFile(filepath.Join(getConfigDir(), "credentials.json"))
+	if errors.Is(err, fs.ErrNotExist) && os.Getenv("GTASKS_CREDENTIALS") != "" {
+		b, err = []byte(os.Getenv("GTASKS_CREDENTIALS")), nil
+	}
 	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		fatalf("Unable to read client secret file: %v", err)
 	}
 
 	// If modifying these scopes, delete your previously saved token.json.
-	config, err := google.ConfigFromJSON(b, tasks.TasksScope)
+	scope := tasks.TasksScope
+	if *readonly {
+		scope = tasks.TasksReadonlyScope
+	}
+	config, err := google.ConfigFromJSON(b, scope, emailScope)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		fatalf("Unable to parse client secret file to config: %v", err)
+	}
+	if cmd == "auth" {
+		authorize(ctx, config)
+		exit(0)
+	}
+	client, granted := getClient(ctx, config)
+	if cmd == "whoami" {
+		whoami(client, granted)
+		exit(0)
+	}
+	if cmd == "print-token" {
+		printToken(client)
+		exit(0)
+	}
+	if needsWrite(cmd) {
+		checkAccount(client, granted)
 	}
-	client := getClient(config)
 
 	srv, err := tasks.NewService(ctx, option.WithHTTPClient(client))
 	if err != nil {
-		log.Fatalf("Unable to retrieve tasks client: %v", err)
+		fatalf("Unable to retrieve tasks client: %v", err)
 	}
-	
-	tasklistIds := make(map[string]string)
-	tasklists, err := srv.Tasklists.List().Do()
-	if err != nil {
-		log.Fatalf("Unable to retrieve tasks lists: %v", err)
+
+	runHook("pre-hook")
+	runCommand(srv, loadTasklists(srv), granted)
+	if timedOut() {
+		fatalf("Timed out after %v", *overallTime)
 	}
-	for _, item := range tasklists.Items {
-		tasklistIds[item.Title] = item.Id
+	if interrupted.Err() != nil {
+		fatalf("Interrupted")
 	}
+	runHook("post-hook")
+	exit(0)
+}
 
-	flag.Parse()
-	cmd := flag.Arg(0)
-	tasklistName := flag.Arg(1)
-	tasklistId := tasklistIds[tasklistName]
-	if tasklistId == "" {
-		log.Fatalf("Tasklist does not exist: %s", tasklistName)
+// Runs the command given by the positional arguments. granted are the scopes
+// of the token in use.
+func runCommand(srv *tasks.Service, tasklists []*tasks.TaskList, granted string) {
+	cmd := arg(0)
+	if needsWrite(cmd) && isReadOnly(granted) {
+		fatalf("The %s command needs write access, but only read access was granted. "+
+			"Delete %s and run gtasks again to re-authorize with write access.",
+			cmd, filepath.Join(getConfigDir(), "token.json"))
+	}
+
+	tasklists = append(tasklists, createdTasklists...)
+	tasklistIds := make(map[string]string)
+	for _, item := range tasklists {
+		tasklistIds[item.Title] = item.Id
+	}
+	if i := targetListArg(cmd); *createList && i > 0 {
+		if name := arg(i); name != "" && tasklistIds[name] == "" {
+			created := createTasklist(srv, name)
+			tasklists = append(tasklists, created)
+			tasklistIds[name] = created.Id
+		}
 	}
 
 	switch cmd {
+	case "batch":
+		if inBatch {
+			fatalf("A batch can't run another batch")
+		}
+		batch(srv, tasklists, granted, arg(1))
+	case "replay":
+		replay(srv, tasklists, arg(1))
+	case "backup":
+		backup(srv, filterTasklists(tasklists), arg(1))
+	case "archive":
+		selected, next := selectTasklists(tasklists, tasklistIds, 1)
+		archive(srv, selected, arg(next))
+	case "export":
+		selected, next := selectTasklists(tasklists, tasklistIds, 1)
+		export(srv, selected, arg(next))
+	case "calendar":
+		selected, _ := selectTasklists(tasklists, tasklistIds, 1)
+		calendar(srv, selected)
 	case "add":
-		title := flag.Arg(2)
-		notes := ""
-		due := ""
-		if flag.NArg() > 3 {
-			notes = flag.Arg(3)
-		}
-		if flag.NArg() > 4 {
-			due = flag.Arg(4)
-		}
-		_, err := srv.Tasks.Insert(tasklistId, &tasks.Task{
-			Title: title,
-			Notes: notes,
-			Due: due,
-		}).Do()
-		if err != nil {
-			log.Fatalf("Could not add task: %v", err)
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		add(srv, tasklistId, tasklistTitle(tasklistIds, tasklistId), args[min(2, len(args)):])
+	case "lists":
+		lists(srv, filterTasklists(tasklists))
+	case "tasklist-info":
+		selected, _ := selectTasklists(tasklists, tasklistIds, 1)
+		if len(selected) != 1 {
+			usageError("tasklist-info shows a single tasklist, leave out --all")
 		}
+		tasklistInfo(srv, selected[0])
 	case "list":
-		tasks, err := srv.Tasks.List(tasklistId).ShowHidden(true).Do()
-		if err != nil {
-			log.Fatalf("Could not list tasklist items: %v", err)
+		if *perList > 0 && !*all {
+			usageError("--limit-per-list only works with --all")
 		}
-		bs, err := json.Marshal(tasks.Items)
-		if err != nil {
-			log.Fatalf("Failure when marshaling items: %v", err)
-		}
-		fmt.Print(string(bs))
+		selected, _ := selectTasklists(tasklists, tasklistIds, 1)
+		list(srv, selected)
 	case "check":
-		taskId := flag.Arg(2)
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		taskId := arg(2)
 		task, err := srv.Tasks.Get(tasklistId, taskId).Do()
 		if err != nil {
-			log.Fatalf("Retrieving task failed: %v", err)
+			fatalf("Retrieving task failed: %v", err)
 		}
 		task.Status = "completed"
 		_, err = srv.Tasks.Update(tasklistId, taskId, task).Do()
 		if err != nil {
-			log.Fatalf("Update task failed: %v", err)
+			fatalf("Update task failed: %v", err)
 		}
-	case "uncheck":
-		taskId := flag.Arg(2)
-		task, err := srv.Tasks.Get(tasklistId, taskId).Do()
+	case "uncheck", "reopen":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		_, err := srv.Tasks.Patch(tasklistId, arg(2), reopenPatch()).Do()
 		if err != nil {
-			log.Fatalf("Retrieving task failed: %v", err)
+			fatalf("Update task failed: %v", err)
 		}
-		task.Status = "needsAction"
-		_, err = srv.Tasks.Update(tasklistId, taskId, task).Do()
-		if err != nil {
-			log.Fatalf("Update task failed: %v", err)
+	case "move":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		destId := ""
+		if *destList != "" {
+			destId = getTasklistId(tasklistIds, *destList)
+		}
+		move(srv, tasklistId, arg(2), destId)
+	case "find-duplicates":
+		selected, _ := selectTasklists(tasklists, tasklistIds, 1)
+		findDuplicates(srv, selected)
+	case "import-markdown":
+		tasklistId := getTasklistId(tasklistIds, arg(2))
+		importMarkdown(srv, tasklistId, arg(1))
+	case "first":
+		first(srv, getTasklistId(tasklistIds, arg(1)))
+	case "pick":
+		selected, _ := selectTasklists(tasklists, tasklistIds, 1)
+		pick(srv, selected)
+	case "search":
+		selected, next := selectTasklists(tasklists, tasklistIds, 1)
+		search(srv, selected, arg(next))
+	case "remind":
+		switch {
+		case *cancel != "":
+			cancelReminder(*cancel)
+		case arg(1) == "":
+			listReminders()
+		default:
+			remind(srv, getTasklistId(tasklistIds, arg(1)), arg(2), arg(3))
+		}
+	case "notify":
+		notify(srv, arg(1), arg(2))
+	case "tui":
+		tasklistId := ""
+		if arg(1) != "" {
+			tasklistId = getTasklistId(tasklistIds, arg(1))
 		}
+		runTui(srv, tasklists, tasklistId)
+	case "swap":
+		swap(srv, getTasklistId(tasklistIds, arg(1)), arg(2), arg(3))
+	case "unparent":
+		unparent(srv, getTasklistId(tasklistIds, arg(1)), arg(2))
+	case "reparent":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		reparent(srv, tasklistId, arg(2), arg(3))
+	case "snooze":
+		snooze(srv, getTasklistId(tasklistIds, arg(1)), arg(2), arg(3))
+	case "show":
+		show(srv, getTasklistId(tasklistIds, arg(1)), arg(2))
+	case "describe":
+		describe(srv, getTasklistId(tasklistIds, arg(1)), arg(2))
+	case "cat":
+		catNotes(srv, getTasklistId(tasklistIds, arg(1)), arg(2))
+	case "sort":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		sortList(srv, tasklistId, tasklistTitle(tasklistIds, tasklistId))
+	case "rename":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		rename(srv, tasklistId, arg(2), arg(3))
+	case "rename-list":
+		if *regex {
+			renameLists(srv, tasklists, filterTasklists(tasklists), arg(1))
+		} else {
+			renameList(srv, tasklists, getTasklistId(tasklistIds, arg(1)), arg(2))
+		}
+	case "touch":
+		touch(srv, getTasklistId(tasklistIds, arg(1)), arg(2))
+	case "move-all":
+		moveAll(srv, getTasklistId(tasklistIds, arg(1)), getTasklistId(tasklistIds, arg(2)))
+	case "move-completed":
+		moveCompleted(srv, getTasklistId(tasklistIds, arg(1)), getTasklistId(tasklistIds, arg(2)))
+	case "check-all":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		checkAll(srv, tasklistId)
+	case "prune-empty-lists":
+		pruneEmptyLists(srv, filterTasklists(tasklists))
+	case "purge":
+		selected, _ := selectTasklists(tasklists, tasklistIds, 1)
+		purge(srv, selected)
+	case "empty":
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		empty(srv, tasklistId, tasklistTitle(tasklistIds, tasklistId))
 	case "delete":
-		taskId := flag.Arg(2)
-		if err := srv.Tasks.Delete(tasklistId, taskId).Do(); err != nil {
-			log.Fatalf("Clear Delete: %v", err)
+		tasklistId := getTasklistId(tasklistIds, arg(1))
+		if len(args) < 3 {
+			fatalf("Missing task id")
+		}
+		if !confirmBulk(fmt.Sprintf("Delete %d tasks?", len(args)-2), len(args)-2) {
+			break
 		}
+		results := forEachTask(args[2:], *concurrency, func(taskId string) error {
+			return srv.Tasks.Delete(tasklistId, taskId).Do()
+		})
+		summarize("delete", results)
 	default:
-		log.Fatalf("Unknown command: %v", cmd)
+		usageError("Unknown command: %s", cmd)
+	}
+
+	if i := tasklistArg(cmd); *thenList && needsWrite(cmd) && i > 0 {
+		selected, _ := selectTasklists(tasklists, tasklistIds, i)
+		list(srv, selected)
+	}
+}
+
+// Returns the index of the positional argument naming the tasklist a
+// command works on, or 0 if it doesn't work on a single tasklist given by
+// name, like rename-list, which changes the names, or prune-empty-lists.
+func tasklistArg(cmd string) int {
+	switch cmd {
+	case "batch", "replay", "tui", "rename-list", "prune-empty-lists":
+		return 0
+	case "import-markdown":
+		return 2
+	}
+	return 1
+}
+
+// Returns the index of the positional argument naming the tasklist a command
+// adds tasks to, or 0 if it doesn't add any.
+func targetListArg(cmd string) int {
+	switch cmd {
+	case "add":
+		return 1
+	case "import-markdown", "move-completed", "move-all":
+		return 2
+	}
+	return 0
+}
+
+// createdTasklists are the tasklists created by --create-list, kept for the
+// following lines of a batch.
+var createdTasklists []*tasks.TaskList
+
+// Creates a tasklist for --create-list.
+func createTasklist(srv *tasks.Service, title string) *tasks.TaskList {
+	created, err := srv.Tasklists.Insert(&tasks.TaskList{Title: title}).Do()
+	forgetTasklists()
+	if err != nil {
+		fatalf("Could not create tasklist %s: %v", title, err)
 	}
+	fmt.Fprintf(os.Stderr, "Created tasklist %s\n", title)
+	createdTasklists = append(createdTasklists, created)
+	return created
 }
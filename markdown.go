@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Renders tasklists as markdown, one section per list and a checkbox per
+// task, in the order of the app. Subtasks are indented below their parent by
+// two spaces per level.
+func markdown(lists []backupList) string {
+	var sb strings.Builder
+	for i, bl := range lists {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		fmt.Fprintf(&sb, "## %s\n\n", bl.Title)
+		for _, node := range flatten(bl.Tasks) {
+			task := node.task
+			indent := strings.Repeat("  ", node.depth)
+			check := " "
+			if task.Status == "completed" {
+				check = "x"
+			}
+			fmt.Fprintf(&sb, "%s- [%s] %s", indent, check, task.Title)
+			if task.Due != "" {
+				fmt.Fprintf(&sb, " (due %s)", displayDue(task.Due))
+			}
+			if task.Completed != nil {
+				fmt.Fprintf(&sb, " (completed %s)", displayTime(*task.Completed))
+			}
+			sb.WriteString("\n")
+			for _, line := range append(strings.Split(task.Notes, "\n"), markdownLinks(task)...) {
+				if line != "" {
+					fmt.Fprintf(&sb, "%s  %s\n", indent, line)
+				}
+			}
+		}
+	}
+	return sb.String()
+}
+
+// markdownTask is a checkbox item read from a markdown file.
+type markdownTask struct {
+	indent int
+	task   *tasks.Task
+}
+
+var (
+	checkboxPattern  = regexp.MustCompile(`^([ \t]*)[-*+] \[([ xX])\] (.*)$`)
+	duePattern       = regexp.MustCompile(` \(due ([^)]*)\)`)
+	completedPattern = regexp.MustCompile(` \(completed ([^)]*)\)`)
+)
+
+// Reads the checkbox items of a markdown file, as written by markdown.
+// Other lines following an item become its notes, except headings and lines
+// before the first item, which are ignored.
+func parseMarkdown(r io.Reader) ([]markdownTask, error) {
+	var items []markdownTask
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		m := checkboxPattern.FindStringSubmatch(line)
+		if m == nil {
+			text := strings.TrimSpace(line)
+			if len(items) == 0 || text == "" || strings.HasPrefix(text, "#") {
+				continue
+			}
+			task := items[len(items)-1].task
+			if task.Notes != "" {
+				task.Notes += "\n"
+			}
+			task.Notes += text
+			continue
+		}
+		task := &tasks.Task{Status: "needsAction"}
+		if m[2] != " " {
+			task.Status = "completed"
+		}
+		title := m[3]
+		if completed := completedPattern.FindStringSubmatch(title); completed != nil {
+			layout := dateLayout() + " " + timeLayout()
+			if t, err := time.ParseInLocation(layout, completed[1], location()); err == nil {
+				timestamp := t.UTC().Format(time.RFC3339)
+				task.Completed = &timestamp
+			}
+			title = strings.Replace(title, completed[0], "", 1)
+		}
+		if due := duePattern.FindStringSubmatch(title); due != nil {
+			if parsed, err := parseMarkdownDue(due[1]); err == nil {
+				task.Due = parsed
+				title = strings.Replace(title, due[0], "", 1)
+			}
+		}
+		task.Title = strings.TrimSpace(title)
+		indent := len(strings.ReplaceAll(m[1], "\t", "    "))
+		items = append(items, markdownTask{indent: indent, task: task})
+	}
+	return items, scanner.Err()
+}
+
+// Parses a due as markdown writes it, in --date-format, or as given to
+// add --due.
+func parseMarkdownDue(s string) (string, error) {
+	if t, err := time.Parse(dateLayout(), s); err == nil {
+		return formatDue(t), nil
+	}
+	return parseDue(s)
+}
+
+// Creates a task for every checkbox item of a markdown file, in the same
+// order. Items indented below another one become its subtasks.
+func importMarkdown(srv *tasks.Service, tasklistId, file string) {
+	if !slices.Contains([]string{"none", "title", "title+due"}, *dedupeBy) {
+		usageError("Unknown dedupe-by: %s, expected title, title+due or none", *dedupeBy)
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fatalf("Could not open markdown file: %v", err)
+	}
+	defer f.Close()
+	items, err := parseMarkdown(f)
+	if err != nil {
+		fatalf("Could not read markdown file: %v", err)
+	}
+
+	// With --dedupe-by, tasks already in the list are skipped, and so are
+	// those repeating an item earlier in the file. Their subtasks from the
+	// file go below the task already there.
+	existing := make(map[string]string)
+	if *dedupeBy != "none" {
+		for _, task := range fetchTasks(srv, tasklistId) {
+			if task.Status != "completed" || *inclDone {
+				existing[dedupeKey(task)] = task.Id
+			}
+		}
+	}
+
+	type ancestor struct {
+		indent int
+		id     string
+	}
+	var stack []ancestor
+	lastChild := make(map[string]string)
+	imported, skipped := 0, 0
+	for _, item := range items {
+		for len(stack) > 0 && stack[len(stack)-1].indent >= item.indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := ""
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1].id
+		}
+		if id, ok := existing[dedupeKey(item.task)]; ok && *dedupeBy != "none" {
+			skipped++
+			stack = append(stack, ancestor{indent: item.indent, id: id})
+			continue
+		}
+		inserted, err := insertCopy(srv, tasklistId, item.task, parent, lastChild[parent])
+		if err != nil {
+			fatalf("Could not add task %q, imported %d of %d tasks before: %v", item.task.Title, imported, len(items), err)
+		}
+		imported++
+		lastChild[parent] = inserted.Id
+		if *dedupeBy != "none" && (item.task.Status != "completed" || *inclDone) {
+			existing[dedupeKey(item.task)] = inserted.Id
+		}
+		stack = append(stack, ancestor{indent: item.indent, id: inserted.Id})
+	}
+	if *dedupeBy != "none" {
+		fmt.Printf("Imported %d tasks, skipped %d already in the list or the file\n", imported, skipped)
+		return
+	}
+	fmt.Printf("Imported %d tasks\n", imported)
+}
+
+// Returns the key tasks are compared by with --dedupe-by: the title,
+// compared like find-duplicates does, and with title+due the due date.
+func dedupeKey(task *tasks.Task) string {
+	title := titleKey(task.Title)
+	switch *dedupeBy {
+	case "title":
+		return title
+	case "title+due":
+		due := ""
+		if task.Due != "" {
+			due = task.Due[:10]
+		}
+		return title + "\x00" + due
+	}
+	return ""
+}
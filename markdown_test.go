@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+func TestMarkdownNesting(t *testing.T) {
+	// In API order, subtasks come before their parents.
+	list := backupList{Title: "Trip", Tasks: []*tasks.Task{
+		{Id: "c", Title: "Charger", Parent: "b", Position: "1", Status: "needsAction"},
+		{Id: "b", Title: "Pack", Parent: "a", Position: "2", Status: "needsAction", Notes: "bag"},
+		{Id: "d", Title: "Book flights", Parent: "a", Position: "1", Status: "completed"},
+		{Id: "a", Title: "Trip", Position: "1", Status: "needsAction"},
+	}}
+	got := markdown([]backupList{list})
+	want := `## Trip
+
+- [ ] Trip
+  - [x] Book flights
+  - [ ] Pack
+    bag
+    - [ ] Charger
+`
+	if got != want {
+		t.Fatalf("markdown is\n%s\nwant\n%s", got, want)
+	}
+
+	items, err := parseMarkdown(strings.NewReader(got))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	var indents []int
+	for _, item := range items {
+		titles = append(titles, item.task.Title)
+		indents = append(indents, item.indent)
+	}
+	if strings.Join(titles, ",") != "Trip,Book flights,Pack,Charger" {
+		t.Errorf("parsed titles are %q", titles)
+	}
+	if indents[0] != 0 || indents[1] != 2 || indents[2] != 2 || indents[3] != 4 {
+		t.Errorf("parsed indents are %v", indents)
+	}
+	if items[2].task.Notes != "bag" {
+		t.Errorf("notes of Pack are %q", items[2].task.Notes)
+	}
+}
+
+// Imports a file repeating an item and one already in the list with
+// --dedupe-by title, which has to add each title once and put the subtasks
+// of a skipped item below the task already there.
+func TestImportMarkdownDedupe(t *testing.T) {
+	api, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"list": {{Id: "a", Title: "Bread", Status: "needsAction"}},
+	})
+	old := *dedupeBy
+	*dedupeBy = "title"
+	t.Cleanup(func() { *dedupeBy = old })
+	file := filepath.Join(t.TempDir(), "tasks.md")
+	content := "- [ ] Milk\n- [ ]  milk \n- [ ] bread\n  - [ ] Rye\n- [ ] Eggs\n- [ ] MILK\n"
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	importMarkdown(srv, "list", file)
+
+	var got []string
+	for _, fields := range api.lists["list"] {
+		var title, parent string
+		json.Unmarshal(fields["title"], &title)
+		json.Unmarshal(fields["parent"], &parent)
+		if parent != "" {
+			title = parent + "/" + title
+		}
+		got = append(got, title)
+	}
+	if strings.Join(got, ",") != "Bread,Milk,a/Rye,Eggs" {
+		t.Errorf("tasks are %q, want Bread, Milk, a/Rye and Eggs", got)
+	}
+}
+
+// Reads back the dues and completion times markdown writes in every
+// --date-format.
+func TestMarkdownDateFormats(t *testing.T) {
+	pinNow(t, "2024-06-05T10:00:00Z")
+	old := *dateFormat
+	t.Cleanup(func() { *dateFormat = old })
+	completed := "2024-06-02T15:30:00Z"
+	list := backupList{Title: "Work", Tasks: []*tasks.Task{
+		{Id: "a", Title: "Report", Status: "completed", Due: "2024-06-01T00:00:00.000Z", Completed: &completed},
+	}}
+	for _, format := range []string{"iso", "us", "eu"} {
+		*dateFormat = format
+		items, err := parseMarkdown(strings.NewReader(markdown([]backupList{list})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("%s: got %d items, want 1", format, len(items))
+		}
+		task := items[0].task
+		if task.Title != "Report" || task.Due != "2024-06-01T00:00:00Z" {
+			t.Errorf("%s: read back title %q, due %q", format, task.Title, task.Due)
+		}
+		if task.Completed == nil || *task.Completed != completed {
+			t.Errorf("%s: read back completion time %v", format, task.Completed)
+		}
+	}
+}
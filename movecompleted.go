@@ -0,0 +1,183 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Copies the completed tasks of one tasklist to another, keeping their
+// completion time. With --clear they are deleted from the source list once
+// copied, except for those with pending subtasks or subtasks that couldn't
+// be copied, which are left where they are, as deleting them would delete
+// the subtasks. Subtasks end up at the top level of the destination.
+func moveCompleted(srv *tasks.Service, sourceId, destId string) {
+	if sourceId == destId {
+		fatalf("Source and destination are the same tasklist")
+	}
+	byId := make(map[string]*tasks.Task)
+	var taskIds []string
+	items := fetchTasks(srv, sourceId)
+	keep := make(map[string]bool)
+	if *clearSource {
+		keep = pendingParents(items)
+	}
+	kept := 0
+	for _, task := range items {
+		if task.Status == "completed" && keep[task.Id] {
+			kept++
+			continue
+		}
+		if task.Status == "completed" && !task.Deleted {
+			byId[task.Id] = task
+			taskIds = append(taskIds, task.Id)
+		}
+	}
+	if kept > 0 {
+		fmt.Fprintf(os.Stderr, "Keeping %d completed tasks with pending subtasks\n", kept)
+	}
+	if len(taskIds) == 0 {
+		fmt.Println("Nothing to move")
+		return
+	}
+	if *dryRun {
+		for _, taskId := range taskIds {
+			fmt.Printf("Would move %s\n", byId[taskId].Title)
+		}
+		fmt.Printf("Would move %d tasks\n", len(taskIds))
+		return
+	}
+	if *clearSource && !confirmBulk(fmt.Sprintf("Move %d completed tasks, deleting them from the source list?", len(taskIds)), len(taskIds)) {
+		return
+	}
+
+	results := forEachTask(taskIds, *concurrency, func(taskId string) error {
+		_, err := insertCopy(srv, destId, byId[taskId], "", "")
+		return err
+	})
+	var copied []string
+	for _, r := range results {
+		if r.err == nil {
+			copied = append(copied, r.taskId)
+		}
+	}
+	if *clearSource && len(copied) > 0 {
+		// A task is only deleted if its completed subtasks were copied as
+		// well, as they are deleted with it, and subtasks of a task being
+		// deleted aren't deleted again.
+		all := tasksById(items)
+		uncopied := make(map[string]bool)
+		for _, r := range results {
+			if r.err != nil {
+				for _, id := range ancestors(all, r.taskId) {
+					uncopied[id] = true
+				}
+			}
+		}
+		var deleting []string
+		for _, taskId := range copied {
+			if !uncopied[taskId] {
+				deleting = append(deleting, taskId)
+			}
+		}
+		roots := deletionRoots(all, deleting)
+		var rootIds []string
+		for _, taskId := range deleting {
+			if roots[taskId] == taskId {
+				rootIds = append(rootIds, taskId)
+			}
+		}
+		errs := make(map[string]error)
+		for _, r := range forEachTask(rootIds, *concurrency, func(taskId string) error {
+			return srv.Tasks.Delete(sourceId, taskId).Do()
+		}) {
+			errs[r.taskId] = r.err
+		}
+		for i, r := range results {
+			if root, ok := roots[r.taskId]; ok {
+				results[i].err = errs[root]
+			} else if uncopied[r.taskId] {
+				results[i].err = errors.New("copied, but not deleted, as a subtask couldn't be copied")
+			}
+		}
+	}
+	fmt.Printf("Moved %d tasks\n", len(copied))
+	summarize("move", results)
+}
+
+// Moves the tasks of one tasklist matching the filters of list, like --tag,
+// --due-before or --pending, to another, each with its subtasks. Title,
+// notes, due and status are kept. Several tasks are moved in parallel, so
+// they may end up at the top of the destination in any order. A subtask
+// that matches on its own but not its parent ends up at the top level.
+func moveAll(srv *tasks.Service, sourceId, destId string) {
+	if sourceId == destId {
+		fatalf("Source and destination are the same tasklist")
+	}
+	source := fetchTasks(srv, sourceId)
+	byId := tasksById(source)
+	matching := make(map[string]bool)
+	for _, task := range filterTasks(source) {
+		matching[task.Id] = true
+	}
+	// Tasks below a matching task are moved along with it.
+	var taskIds []string
+	subtasks := make(map[string]int)
+	for _, task := range source {
+		if !matching[task.Id] {
+			continue
+		}
+		if !slices.ContainsFunc(ancestors(byId, task.Id), func(id string) bool { return matching[id] }) {
+			taskIds = append(taskIds, task.Id)
+		}
+	}
+	for _, task := range source {
+		for _, id := range ancestors(byId, task.Id) {
+			subtasks[id]++
+		}
+	}
+	if len(taskIds) == 0 {
+		fmt.Println("Nothing to move")
+		return
+	}
+	total := len(taskIds)
+	for _, taskId := range taskIds {
+		total += subtasks[taskId]
+	}
+	if *dryRun {
+		for _, taskId := range taskIds {
+			if n := subtasks[taskId]; n > 0 {
+				fmt.Printf("Would move %s with %d subtasks\n", byId[taskId].Title, n)
+			} else {
+				fmt.Printf("Would move %s\n", byId[taskId].Title)
+			}
+		}
+		fmt.Printf("Would move %d tasks\n", total)
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("Move %d tasks?", total), total) {
+		return
+	}
+
+	nodes := flatten(source)
+	var moved atomic.Int64
+	// The errors of copySubtree and deleteSubtree aren't retried, so that a
+	// task copied in part isn't copied again.
+	results := forEachTask(taskIds, *concurrency, func(taskId string) error {
+		copied, err := copySubtree(srv, destId, nodes, byId, taskId, "", "")
+		if err != nil {
+			return err
+		}
+		if err := deleteSubtree(srv, sourceId, byId, copied); err != nil {
+			return err
+		}
+		moved.Add(int64(len(copied)))
+		return nil
+	})
+	fmt.Printf("Moved %d tasks\n", moved.Load())
+	summarize("move", results)
+}
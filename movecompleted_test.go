@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Moves a completed task with a completed subtask with --clear, which has to
+// copy both and delete them from the source with a single delete.
+func TestMoveCompletedClear(t *testing.T) {
+	api, srv := newFakeAPI(t, map[string][]*tasks.Task{
+		"source": {
+			{Id: "a", Title: "Trip", Status: "completed"},
+			{Id: "b", Title: "Pack", Parent: "a", Status: "completed"},
+			{Id: "c", Title: "Call", Status: "needsAction"},
+		},
+		"dest": {},
+	})
+	old := *clearSource
+	*clearSource = true
+	t.Cleanup(func() { *clearSource = old })
+
+	moveCompleted(srv, "source", "dest")
+
+	if len(api.lists["dest"]) != 2 {
+		t.Errorf("destination has %d tasks, want 2", len(api.lists["dest"]))
+	}
+	if len(api.lists["source"]) != 1 {
+		t.Errorf("source has %d tasks, want only the pending one", len(api.lists["source"]))
+	}
+	if api.deletes != 1 {
+		t.Errorf("%d deletes, want 1 for the task with its subtask", api.deletes)
+	}
+}
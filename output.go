@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"syscall"
+)
+
+// stdout is where tasks are printed. It is flushed after every page or batch
+// of tasks printed, so that output shows up as it comes when piped, and
+// before exiting.
+var stdout = bufio.NewWriter(&pipeWriter{w: os.Stdout})
+
+func init() {
+	exitHooks = append(exitHooks, func() { stdout.Flush() })
+}
+
+// pipeWriter ends gtasks quietly, with exit code 0, once whoever reads the
+// output has gone away, like head after printing its lines. This needs
+// SIGPIPE to be ignored, otherwise the write kills the process first.
+type pipeWriter struct {
+	w      io.Writer
+	broken bool
+}
+
+func (p *pipeWriter) Write(b []byte) (int, error) {
+	if p.broken {
+		return len(b), nil
+	}
+	n, err := p.w.Write(b)
+	if errors.Is(err, syscall.EPIPE) {
+		p.broken = true
+		runExitHooks()
+		os.Exit(0)
+	}
+	return n, err
+}
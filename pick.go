@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"math/rand/v2"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Prints a randomly chosen pending task of the given tasklists, for when
+// you can't decide what to do next.
+func pick(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	var pending []*tasks.Task
+	for _, tasklist := range tasklists {
+		for _, task := range filterTasks(fetchTasks(srv, tasklist.Id)) {
+			if task.Status != "completed" {
+				pending = append(pending, task)
+			}
+		}
+	}
+	if len(pending) == 0 {
+		fatalf("No pending tasks to pick from")
+	}
+	printTasks([]*tasks.Task{pending[rand.IntN(len(pending))]})
+}
+
+// Prints the id of the first pending task of a tasklist in the order of the
+// list, subtasks following their parent, or the whole task if --format is
+// given. Prints nothing if there is no pending task.
+func first(srv *tasks.Service, tasklistId string) {
+	items := fetchTasks(srv, tasklistId)
+	matching := tasksById(filterTasks(items))
+	for _, node := range flatten(items) {
+		if node.task.Status == "completed" || matching[node.task.Id] == nil {
+			continue
+		}
+		if flagGiven("format") || *plain || *tmplFile != "" {
+			printTasks([]*tasks.Task{node.task})
+		} else {
+			fmt.Println(node.task.Id)
+		}
+		return
+	}
+}
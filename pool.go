@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// errSkipped is the result of tasks not attempted because of --strict.
+var errSkipped = errors.New("skipped")
+
+// result is the outcome of a bulk operation on a single task.
+type result struct {
+	taskId string
+	err    error
+}
+
+// Calls f for every task id using at most concurrency goroutines. Each call
+// is retried with backoff. Failures don't stop the other calls, unless
+// --strict is given: then no further calls are started after the first
+// failure and the remaining tasks are skipped. The same happens when
+// interrupted. The results are returned in
+// the order of taskIds.
+func forEachTask(taskIds []string, concurrency int, f func(taskId string) error) []result {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]result, len(taskIds))
+	sem := make(chan struct{}, concurrency)
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i, taskId := range taskIds {
+		sem <- struct{}{}
+		if *strict && failed.Load() || interrupted.Err() != nil {
+			<-sem
+			results[i] = result{taskId: taskId, err: errSkipped}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := withBackoff(func() error { return f(taskId) })
+			if err != nil {
+				failed.Store(true)
+			}
+			results[i] = result{taskId: taskId, err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// Logs the failed results and, for more than one task, prints how many
+// succeeded. Exits non-zero if anything failed.
+func summarize(verb string, results []result) {
+	failed, skipped := 0, 0
+	for _, r := range results {
+		switch {
+		case r.err == errSkipped:
+			skipped++
+		case r.err != nil:
+			failed++
+			if *jsonErrors {
+				printJSONError(fmt.Sprintf("Could not %s %s: %v", verb, r.taskId, r.err), exitFailure, r.err)
+			} else {
+				log.Printf("Could not %s %s: %v", verb, r.taskId, r.err)
+			}
+		}
+	}
+	if len(results) > 1 {
+		fmt.Printf("%d succeeded, %d failed", len(results)-failed-skipped, failed)
+		if skipped > 0 {
+			fmt.Printf(", %d skipped", skipped)
+		}
+		fmt.Println()
+	}
+	if failed > 0 {
+		exit(exitFailure)
+	}
+}
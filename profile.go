@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// Starts CPU profiling and execution tracing as requested by --cpuprofile
+// and --trace. Both are stopped and flushed by an exit hook, so the output
+// is complete even if the command fails.
+func startProfiling() {
+	if *cpuprofile != "" {
+		f, err := os.Create(*cpuprofile)
+		if err != nil {
+			fatalf("Could not create CPU profile: %v", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			fatalf("Could not start CPU profile: %v", err)
+		}
+		exitHooks = append(exitHooks, func() {
+			pprof.StopCPUProfile()
+			f.Close()
+		})
+	}
+	if *traceFile != "" {
+		f, err := os.Create(*traceFile)
+		if err != nil {
+			fatalf("Could not create trace: %v", err)
+		}
+		if err := trace.Start(f); err != nil {
+			fatalf("Could not start trace: %v", err)
+		}
+		exitHooks = append(exitHooks, func() {
+			trace.Stop()
+			f.Close()
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Asks a yes/no question on stdin, defaulting to no. With --yes it doesn't
+// ask and answers yes.
+func confirm(question string) bool {
+	if *yes {
+		return true
+	}
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// Like confirm, but only asks if the operation affects more than
+// --confirm-count tasks, so that small bulk operations just go ahead.
+func confirmBulk(question string, n int) bool {
+	if n <= *maxUnasked {
+		return true
+	}
+	return confirm(question)
+}
+
+// Picks the tasklist a command works on when none is named. With a single
+// tasklist that is the one, otherwise the tasklists are listed and the user
+// is asked for one by number or title. Without a terminal to ask on, or in a
+// batch, a missing tasklist is an error.
+func chooseTasklist(tasklistIds map[string]string) string {
+	var titles []string
+	for title := range tasklistIds {
+		titles = append(titles, title)
+	}
+	slices.Sort(titles)
+	switch {
+	case len(titles) == 1:
+		return titles[0]
+	case len(titles) == 0:
+		fatalf("Missing tasklist")
+	case inBatch || !term.IsTerminal(int(os.Stdin.Fd())):
+		fatalf("Missing tasklist, one of: %s", strings.Join(titles, ", "))
+	}
+	for i, title := range titles {
+		fmt.Fprintf(os.Stderr, "%3d  %s\n", i+1, title)
+	}
+	fmt.Fprintf(os.Stderr, "Tasklist [1-%d]: ", len(titles))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(titles) {
+		return titles[n-1]
+	}
+	if answer == "" {
+		fatalf("Missing tasklist")
+	}
+	return answer
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Deletes the tasks of the given tasklists that are already marked deleted,
+// which the API keeps returning for a while. The API has no other way to get
+// rid of them than deleting them again, so that is what this does.
+func purge(srv *tasks.Service, tasklists []*tasks.TaskList) {
+	var taskIds []string
+	tasklistIds := make(map[string]string)
+	for _, tasklist := range tasklists {
+		items, err := allTasks(srv.Tasks.List(tasklist.Id).ShowCompleted(true).ShowHidden(true).ShowDeleted(true))
+		if err != nil {
+			fatalf("Could not list tasklist items: %v", err)
+		}
+		for _, task := range items {
+			if task.Deleted {
+				taskIds = append(taskIds, task.Id)
+				tasklistIds[task.Id] = tasklist.Id
+			}
+		}
+	}
+	if len(taskIds) == 0 {
+		fmt.Println("Nothing to purge")
+		return
+	}
+	if *dryRun {
+		fmt.Printf("Would purge %d deleted tasks\n", len(taskIds))
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("Purge %d deleted tasks?", len(taskIds)), len(taskIds)) {
+		return
+	}
+	results := forEachTask(taskIds, *concurrency, func(taskId string) error {
+		return srv.Tasks.Delete(tasklistIds[taskId], taskId).Do()
+	})
+	purged := 0
+	for _, r := range results {
+		if r.err == nil {
+			purged++
+		}
+	}
+	fmt.Printf("Purged %d tasks\n", purged)
+	summarize("purge", results)
+}
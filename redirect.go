@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+
+	"golang.org/x/oauth2"
+)
+
+// redirectResult is what the browser brings back to the redirect server:
+// the authorization code, or the error Google reported instead.
+type redirectResult struct {
+	code string
+	err  string
+}
+
+// Authorizes through a server on the given localhost port that Google
+// redirects the browser to, instead of having the code copied by hand.
+func tokenFromRedirect(ctx context.Context, config *oauth2.Config, port int) *storedToken {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if errors.Is(err, syscall.EADDRINUSE) {
+		fatalf("Port %d is already in use, choose another one with --auth-port", port)
+	}
+	if err != nil {
+		fatalf("Unable to start the redirect server: %v", err)
+	}
+	b := make([]byte, 16)
+	rand.Read(b)
+	state := hex.EncodeToString(b)
+	conf := *config
+	conf.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d/", port)
+
+	results := make(chan redirectResult, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("state") != state {
+			http.Error(w, "Invalid state", http.StatusBadRequest)
+			return
+		}
+		result := redirectResult{code: q.Get("code"), err: q.Get("error")}
+		if result.err != "" {
+			fmt.Fprintf(w, "Authorization failed: %s\n", result.err)
+		} else {
+			fmt.Fprintln(w, "gtasks is authorized, you can close this window.")
+		}
+		select {
+		case results <- result:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	authURL := conf.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	fmt.Printf("Go to the following link in your browser to authorize gtasks: \n%v\n", authURL)
+	if !*noBrowser {
+		openBrowser(authURL)
+	}
+	select {
+	case result := <-results:
+		if result.err != "" {
+			fatalf("Authorization failed: %s", result.err)
+		}
+		return exchangeCode(ctx, &conf, result.code)
+	case <-ctx.Done():
+		fatalf("Interrupted")
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// reminder is a notification scheduled with the system scheduler, which
+// runs "gtasks notify <tasklistId> <taskId>" at the given time.
+type reminder struct {
+	TasklistId string `json:"tasklistId"`
+	TaskId     string `json:"taskId"`
+	Title      string `json:"title"`
+	At         string `json:"at"`
+}
+
+// The file reminders.json keeps track of the scheduled reminders, so they
+// can be listed and canceled.
+func remindersFile() string {
+	return filepath.Join(getConfigDir(), "reminders.json")
+}
+
+func loadReminders() []reminder {
+	var reminders []reminder
+	b, err := os.ReadFile(remindersFile())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		fatalf("Could not read reminders: %v", err)
+	}
+	if err := json.Unmarshal(b, &reminders); err != nil {
+		fatalf("Could not parse %s: %v", remindersFile(), err)
+	}
+	return reminders
+}
+
+func saveReminders(reminders []reminder) {
+	b, err := json.MarshalIndent(reminders, "", "  ")
+	if err != nil {
+		fatalf("Failure when marshaling reminders: %v", err)
+	}
+	if err := os.WriteFile(remindersFile(), b, 0600); err != nil {
+		fatalf("Could not write reminders: %v", err)
+	}
+}
+
+// Parses when a reminder is due: an RFC3339 timestamp, an offset from now
+// like "+2h", or a due date as accepted by add with an optional time of day.
+// Without a time of day, reminders are at 9:00.
+func parseReminderTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if strings.HasPrefix(s, "+") {
+		if d, err := parseDuration(s[1:]); err == nil {
+			return currentTime().Add(d), nil
+		}
+	}
+	due, clock, err := parseDueTime(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if clock == "" {
+		clock = "09:00"
+	}
+	date, _ := time.Parse(time.RFC3339, due)
+	c, _ := time.Parse("15:04", clock)
+	return time.Date(date.Year(), date.Month(), date.Day(), c.Hour(), c.Minute(), 0, 0, location()), nil
+}
+
+// Schedules a reminder for a task, replacing an earlier one for the same task.
+func remind(srv *tasks.Service, tasklistId, taskId, when string) {
+	if taskId == "" || when == "" {
+		usageError("Missing task id or time of the reminder")
+	}
+	at, err := parseReminderTime(when)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	if at.Before(currentTime()) {
+		fatalf("The time of the reminder is in the past: %s", at.Format(time.RFC3339))
+	}
+	task, err := srv.Tasks.Get(tasklistId, taskId).Do()
+	if err != nil {
+		fatalf("Retrieving task failed: %v", err)
+	}
+
+	r := reminder{TasklistId: tasklistId, TaskId: taskId, Title: task.Title, At: at.Format(time.RFC3339)}
+	reminders := loadReminders()
+	if i := slices.IndexFunc(reminders, func(o reminder) bool { return o.TaskId == taskId }); i >= 0 {
+		unschedule(reminders[i])
+		reminders = slices.Delete(reminders, i, i+1)
+	}
+	if err := schedule(r, at); err != nil {
+		fatalf("Could not schedule reminder: %v", err)
+	}
+	saveReminders(append(reminders, r))
+	fmt.Printf("Reminding of %q at %s\n", task.Title, displayTime(r.At))
+}
+
+// Cancels the reminder for a task.
+func cancelReminder(taskId string) {
+	reminders := loadReminders()
+	i := slices.IndexFunc(reminders, func(r reminder) bool { return r.TaskId == taskId })
+	if i < 0 {
+		fatalf("No reminder for task %s", taskId)
+	}
+	if err := unschedule(reminders[i]); err != nil {
+		fatalf("Could not cancel reminder: %v", err)
+	}
+	saveReminders(slices.Delete(reminders, i, i+1))
+}
+
+// Prints the scheduled reminders, soonest first.
+func listReminders() {
+	reminders := loadReminders()
+	slices.SortFunc(reminders, func(a, b reminder) int { return strings.Compare(a.At, b.At) })
+	for _, r := range reminders {
+		fmt.Printf("%s\t%s\t%s\n", displayTime(r.At), r.TaskId, escapePlain(r.Title))
+	}
+}
+
+// Shows the notification of a reminder, unless the task has been completed
+// or deleted in the meantime. This is what the scheduler runs.
+func notify(srv *tasks.Service, tasklistId, taskId string) {
+	reminders := loadReminders()
+	if i := slices.IndexFunc(reminders, func(r reminder) bool { return r.TaskId == taskId }); i >= 0 {
+		unschedule(reminders[i])
+		saveReminders(slices.Delete(reminders, i, i+1))
+	}
+	task, err := srv.Tasks.Get(tasklistId, taskId).Do()
+	if err != nil {
+		fatalf("Retrieving task failed: %v", err)
+	}
+	if task.Status == "completed" || task.Deleted {
+		return
+	}
+	if err := showNotification("gtasks", task.Title); err != nil {
+		fmt.Println(task.Title)
+	}
+}
+
+// Names the scheduler entry of a reminder. Task ids only contain letters,
+// digits, dashes and underscores.
+func reminderName(r reminder) string {
+	return "gtasks-remind-" + r.TaskId
+}
+
+// Creates a one-shot entry in the system scheduler that runs gtasks notify.
+func schedule(r reminder, at time.Time) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	local := at.Local()
+	switch runtime.GOOS {
+	case "linux":
+		if _, err := exec.LookPath("systemd-run"); err != nil {
+			return errors.New("systemd-run not found, reminders need systemd user timers on Linux")
+		}
+		return run("systemd-run", "--user", "--unit="+reminderName(r),
+			"--on-calendar="+local.Format(time.DateTime), "--timer-property=AccuracySec=1s",
+			exe, "notify", r.TasklistId, r.TaskId)
+	case "darwin":
+		plist := launchAgentFile(r)
+		content := fmt.Sprintf(launchAgentTemplate, "com."+reminderName(r), exe, r.TasklistId, r.TaskId,
+			int(local.Month()), local.Day(), local.Hour(), local.Minute())
+		if err := os.WriteFile(plist, []byte(content), 0644); err != nil {
+			return err
+		}
+		return run("launchctl", "load", plist)
+	case "windows":
+		return run("schtasks", "/Create", "/F", "/SC", "ONCE", "/TN", reminderName(r),
+			"/TR", fmt.Sprintf(`"%s" notify %s %s`, exe, r.TasklistId, r.TaskId),
+			"/SD", local.Format("01/02/2006"), "/ST", local.Format("15:04"))
+	}
+	return fmt.Errorf("no supported scheduler on %s", runtime.GOOS)
+}
+
+// Removes the scheduler entry of a reminder.
+func unschedule(r reminder) error {
+	switch runtime.GOOS {
+	case "linux":
+		return run("systemctl", "--user", "stop", reminderName(r)+".timer")
+	case "darwin":
+		plist := launchAgentFile(r)
+		run("launchctl", "unload", plist)
+		return os.Remove(plist)
+	case "windows":
+		return run("schtasks", "/Delete", "/F", "/TN", reminderName(r))
+	}
+	return nil
+}
+
+func launchAgentFile(r reminder) string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "Library", "LaunchAgents", "com."+reminderName(r)+".plist")
+}
+
+// launchd has no one-shot jobs, the job runs on the given month, day, hour
+// and minute and notify removes it after the first run.
+const launchAgentTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>%s</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>%s</string>
+		<string>notify</string>
+		<string>%s</string>
+		<string>%s</string>
+	</array>
+	<key>StartCalendarInterval</key>
+	<dict>
+		<key>Month</key>
+		<integer>%d</integer>
+		<key>Day</key>
+		<integer>%d</integer>
+		<key>Hour</key>
+		<integer>%d</integer>
+		<key>Minute</key>
+		<integer>%d</integer>
+	</dict>
+</dict>
+</plist>
+`
+
+// Shows a desktop notification.
+func showNotification(title, text string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", text, title)
+		return run("osascript", "-e", script)
+	case "windows":
+		return run("msg", "*", title+": "+text)
+	}
+	return run("notify-send", title, text)
+}
+
+// Runs a command, returning its output as part of the error if it fails.
+func run(name string, arg ...string) error {
+	out, err := exec.Command(name, arg...).CombinedOutput()
+	if err != nil && len(out) > 0 {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return err
+}
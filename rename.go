@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Changes the title of a task, given by id or title, leaving everything
+// else as it is.
+func rename(srv *tasks.Service, tasklistId, idOrTitle, title string) {
+	if strings.TrimSpace(title) == "" {
+		fatalf("The new title must not be empty")
+	}
+	task := findTask(fetchTasks(srv, tasklistId), idOrTitle)
+	if _, err := srv.Tasks.Patch(tasklistId, task.Id, &tasks.Task{Title: title}).Do(); err != nil {
+		fatalf("Could not rename task: %v", err)
+	}
+}
+
+// Bumps the update time of a task, given by id or title, without changing
+// it, by setting its title to what it already is. Sorting by updated then
+// puts it first or last.
+func touch(srv *tasks.Service, tasklistId, idOrTitle string) {
+	task := findTask(fetchTasks(srv, tasklistId), idOrTitle)
+	patched, err := srv.Tasks.Patch(tasklistId, task.Id, &tasks.Task{Title: task.Title}).Do()
+	if err != nil {
+		fatalf("Could not touch task: %v", err)
+	}
+	fmt.Printf("Touched %q, updated %s\n", patched.Title, displayTime(patched.Updated))
+}
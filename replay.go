@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// replayStep is a request of the audit log made ready to be sent again.
+type replayStep struct {
+	desc string
+	// do makes the request and returns the id of what it created, if
+	// anything.
+	do func() (string, error)
+}
+
+// Makes the changes recorded in an audit log again, in the order they were
+// made. Requests that failed the first time are left out. Ids of tasks and
+// tasklists created along the way are mapped to those of their new copies,
+// and --list-map maps the ids of other tasklists in the log, e.g. for
+// replaying on another account. Requests that no longer apply, like
+// changing a task that doesn't exist anymore, are skipped with a warning.
+func replay(srv *tasks.Service, tasklists []*tasks.TaskList, file string) {
+	if file == "" {
+		fatalf("Missing audit log")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		fatalf("Could not read audit log: %v", err)
+	}
+	defer f.Close()
+
+	ids := parseListMap(tasklists)
+	titles := make(map[string]string)
+	for _, tasklist := range tasklists {
+		titles[tasklist.Id] = tasklist.Title
+	}
+	replayed, skipped := 0, 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry auditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			fatalf("Could not parse line %d of %s: %v", line, file, err)
+		}
+		if entry.Error != "" || entry.Status < 200 || entry.Status >= 300 {
+			continue
+		}
+		step, err := replayStepOf(srv, entry, ids, titles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping line %d: %v\n", line, err)
+			skipped++
+			continue
+		}
+		if *dryRun {
+			fmt.Printf("Would %s\n", step.desc)
+			continue
+		}
+		var created string
+		err = withBackoff(func() error {
+			var err error
+			created, err = step.do()
+			return err
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Skipping line %d, could not %s: %v\n", line, step.desc, err)
+			skipped++
+			continue
+		}
+		if entry.Created != "" && created != "" {
+			ids[entry.Created] = created
+		}
+		replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		fatalf("Could not read audit log: %v", err)
+	}
+	if !*dryRun {
+		fmt.Printf("Replayed %d requests, skipped %d\n", replayed, skipped)
+	}
+}
+
+// Parses --list-map, comma separated old=new pairs mapping the id of a
+// tasklist in the audit log to a tasklist of this account, given by title
+// or id.
+func parseListMap(tasklists []*tasks.TaskList) map[string]string {
+	ids := make(map[string]string)
+	if *listMap == "" {
+		return ids
+	}
+	for _, pair := range strings.Split(*listMap, ",") {
+		old, name, ok := strings.Cut(pair, "=")
+		old, name = strings.TrimSpace(old), strings.TrimSpace(name)
+		if !ok || old == "" || name == "" {
+			usageError("Invalid list mapping %q, expected old=new", pair)
+		}
+		ids[old] = ""
+		for _, tasklist := range tasklists {
+			if tasklist.Title == name || tasklist.Id == name {
+				ids[old] = tasklist.Id
+				break
+			}
+		}
+		if ids[old] == "" {
+			fatalf("Tasklist does not exist: %s", name)
+		}
+	}
+	return ids
+}
+
+// Turns an audit log entry back into the API call it recorded, with ids
+// mapped through ids.
+func replayStepOf(srv *tasks.Service, entry auditEntry, ids, titles map[string]string) (replayStep, error) {
+	if entry.Path == "" {
+		return replayStep{}, fmt.Errorf("%s request was logged without its details", entry.Method)
+	}
+	mapId := func(id string) string {
+		if mapped, ok := ids[id]; ok {
+			return mapped
+		}
+		return id
+	}
+	query, err := url.ParseQuery(entry.Query)
+	if err != nil {
+		return replayStep{}, fmt.Errorf("invalid query: %v", err)
+	}
+	parent, previous := mapId(query.Get("parent")), mapId(query.Get("previous"))
+	list := mapId(entry.List)
+	task := mapId(entry.Task)
+	listName := titles[list]
+	if listName == "" {
+		listName = list
+	}
+
+	parts := strings.Split(strings.TrimPrefix(strings.Trim(entry.Path, "/"), "tasks/v1/"), "/")
+	tasklistPath := len(parts) >= 3 && parts[0] == "users" && parts[2] == "lists"
+	switch {
+	case tasklistPath && len(parts) == 3 && entry.Method == "POST":
+		body := &tasks.TaskList{}
+		if err := replayBody(entry, body); err != nil {
+			return replayStep{}, err
+		}
+		body.ForceSendFields, body.NullFields = bodyFields(entry)
+		return replayStep{fmt.Sprintf("add tasklist %q", body.Title), func() (string, error) {
+			created, err := srv.Tasklists.Insert(body).Do()
+			forgetTasklists()
+			if err != nil {
+				return "", err
+			}
+			titles[created.Id] = created.Title
+			return created.Id, nil
+		}}, nil
+	case tasklistPath && len(parts) == 4 && (entry.Method == "PATCH" || entry.Method == "PUT"):
+		body := &tasks.TaskList{}
+		if err := replayBody(entry, body); err != nil {
+			return replayStep{}, err
+		}
+		body.ForceSendFields, body.NullFields = bodyFields(entry)
+		return replayStep{fmt.Sprintf("change tasklist %s", listName), func() (string, error) {
+			_, err := srv.Tasklists.Patch(list, body).Do()
+			forgetTasklists()
+			return "", err
+		}}, nil
+	case tasklistPath && len(parts) == 4 && entry.Method == "DELETE":
+		return replayStep{fmt.Sprintf("delete tasklist %s", listName), func() (string, error) {
+			defer forgetTasklists()
+			return "", srv.Tasklists.Delete(list).Do()
+		}}, nil
+	case parts[0] != "lists" || len(parts) < 3:
+	case len(parts) == 3 && parts[2] == "clear" && entry.Method == "POST":
+		return replayStep{fmt.Sprintf("clear tasklist %s", listName), func() (string, error) {
+			return "", srv.Tasks.Clear(list).Do()
+		}}, nil
+	case len(parts) == 3 && parts[2] == "tasks" && entry.Method == "POST":
+		body := &tasks.Task{}
+		if err := replayBody(entry, body); err != nil {
+			return replayStep{}, err
+		}
+		body.ForceSendFields, body.NullFields = bodyFields(entry)
+		return replayStep{fmt.Sprintf("add task %q to %s", body.Title, listName), func() (string, error) {
+			call := srv.Tasks.Insert(list, body)
+			if parent != "" {
+				call = call.Parent(parent)
+			}
+			if previous != "" {
+				call = call.Previous(previous)
+			}
+			created, err := call.Do()
+			if err != nil {
+				return "", err
+			}
+			return created.Id, nil
+		}}, nil
+	case len(parts) == 5 && parts[4] == "move" && entry.Method == "POST":
+		return replayStep{fmt.Sprintf("move task %s in %s", task, listName), func() (string, error) {
+			call := srv.Tasks.Move(list, task)
+			if parent != "" {
+				call = call.Parent(parent)
+			}
+			if previous != "" {
+				call = call.Previous(previous)
+			}
+			_, err := call.Do()
+			return "", err
+		}}, nil
+	case len(parts) == 4 && (entry.Method == "PATCH" || entry.Method == "PUT"):
+		body := &tasks.Task{}
+		if err := replayBody(entry, body); err != nil {
+			return replayStep{}, err
+		}
+		body.ForceSendFields, body.NullFields = bodyFields(entry)
+		return replayStep{fmt.Sprintf("change task %s in %s", task, listName), func() (string, error) {
+			_, err := srv.Tasks.Patch(list, task, body).Do()
+			return "", err
+		}}, nil
+	case len(parts) == 4 && entry.Method == "DELETE":
+		return replayStep{fmt.Sprintf("delete task %s from %s", task, listName), func() (string, error) {
+			return "", srv.Tasks.Delete(list, task).Do()
+		}}, nil
+	}
+	return replayStep{}, fmt.Errorf("don't know how to replay %s %s", entry.Method, entry.Path)
+}
+
+// replayIgnored are the fields of a recorded body that belong to the task or
+// tasklist as it was, not to the change, and so aren't sent again.
+var replayIgnored = []string{"id", "etag", "selfLink", "updated", "kind"}
+
+// Decodes the recorded body of a request into v, leaving out the fields of
+// replayIgnored.
+func replayBody(entry auditEntry, v any) error {
+	if len(entry.Body) == 0 {
+		return fmt.Errorf("%s request was logged without its body", entry.Method)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(entry.Body, &fields); err != nil {
+		return fmt.Errorf("invalid body: %v", err)
+	}
+	for _, name := range replayIgnored {
+		delete(fields, name)
+	}
+	b, _ := json.Marshal(fields)
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("invalid body: %v", err)
+	}
+	return nil
+}
+
+// Returns the fields of a recorded body that had a zero value and those that
+// were null, for ForceSendFields and NullFields, so that a patch clearing a
+// field still does.
+func bodyFields(entry auditEntry) (force, null []string) {
+	var fields map[string]json.RawMessage
+	json.Unmarshal(entry.Body, &fields)
+	for name, value := range fields {
+		if slices.Contains(replayIgnored, name) {
+			continue
+		}
+		field := strings.ToUpper(name[:1]) + name[1:]
+		if string(value) == "null" {
+			null = append(null, field)
+		} else {
+			force = append(force, field)
+		}
+	}
+	return force, null
+}
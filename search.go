@@ -0,0 +1,49 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Prints the tasks of the given tasklists whose title or notes contain text,
+// ignoring case. --in restricts the search to the title or the notes. With
+// --regex, text is a regular expression instead.
+func search(srv *tasks.Service, tasklists []*tasks.TaskList, text string) {
+	if text == "" {
+		usageError("Missing search text")
+	}
+	inTitle, inNotes := true, true
+	switch *searchIn {
+	case "title":
+		inNotes = false
+	case "notes":
+		inTitle = false
+	case "both":
+	default:
+		usageError("Unknown search scope: %s, expected title, notes or both", *searchIn)
+	}
+	keys := parseSort()
+	match := func(s string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(text))
+	}
+	if *regex {
+		pattern, err := regexp.Compile("(?i)" + text)
+		if err != nil {
+			usageError("Invalid regular expression: %v", err)
+		}
+		match = pattern.MatchString
+	}
+	var found []*tasks.Task
+	for _, tasklist := range tasklists {
+		for _, task := range filterTasks(fetchTasks(srv, tasklist.Id)) {
+			if inTitle && match(task.Title) || inNotes && match(task.Notes) {
+				found = append(found, task)
+			}
+		}
+	}
+	sortTasks(found, keys)
+	printTasks(found)
+	failIfEmpty(len(found))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Prints everything about a single task, given by id or title, including
+// the links of tasks created from Gmail or Docs.
+func show(srv *tasks.Service, tasklistId, idOrTitle string) {
+	items := fetchTasks(srv, tasklistId)
+	task := findTask(items, idOrTitle)
+	field := func(name, value string) {
+		if value != "" {
+			fmt.Printf("%-11s%s\n", name+":", value)
+		}
+	}
+	field("Id", task.Id)
+	field("Title", task.Title)
+	status := "pending"
+	if task.Status == "completed" {
+		status = "completed"
+	}
+	field("Status", status)
+	field("Due", humanTaskDue(task))
+	if task.Completed != nil {
+		field("Completed", displayTime(*task.Completed))
+	}
+	field("Parent", parentName(tasksById(items), task))
+	field("Updated", displayTime(task.Updated))
+	field("Web", task.WebViewLink)
+	if notes := stripDueTime(task.Notes); notes != "" {
+		fmt.Println("Notes:")
+		for _, line := range strings.Split(notes, "\n") {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	if len(task.Links) > 0 {
+		fmt.Println("Links:")
+		for _, link := range task.Links {
+			text := linkText(link)
+			if hyperlinks() {
+				text = hyperlink(link.Link, text)
+			}
+			fmt.Printf("  %s  %s\n", text, link.Link)
+		}
+	}
+}
+
+// Prints a task, given by id or title, as the pretty printed JSON the API
+// returns for it, with every field show leaves out, like the etag and the
+// position. Deleted tasks can be described too.
+func describe(srv *tasks.Service, tasklistId, idOrTitle string) {
+	items, err := allTasks(srv.Tasks.List(tasklistId).ShowCompleted(true).ShowHidden(true).ShowDeleted(true))
+	if err != nil {
+		fatalf("Could not list tasklist items: %v", err)
+	}
+	task, err := srv.Tasks.Get(tasklistId, findTask(items, idOrTitle).Id).Do()
+	if err != nil {
+		fatalf("Retrieving task failed: %v", err)
+	}
+	bs, err := json.MarshalIndent(task, "", "  ")
+	if err != nil {
+		fatalf("Failure when marshaling task: %v", err)
+	}
+	fmt.Fprintln(stdout, string(bs))
+}
+
+// Prints just the notes of a task, given by id or title, for piping them
+// into other tools. The due time marker is left out.
+func catNotes(srv *tasks.Service, tasklistId, idOrTitle string) {
+	task := findTask(fetchTasks(srv, tasklistId), idOrTitle)
+	notes := stripDueTime(task.Notes)
+	if notes == "" {
+		fatalf("Task %q has no notes", task.Title)
+	}
+	fmt.Println(notes)
+}
+
+// Returns what to show for a link: its description, or its type if it has
+// none, or else the URL itself.
+func linkText(link *tasks.TaskLinks) string {
+	switch {
+	case link.Description != "":
+		return link.Description
+	case link.Type != "":
+		return link.Type
+	}
+	return link.Link
+}
+
+// Renders the links of a task as markdown links, one per line.
+func markdownLinks(task *tasks.Task) []string {
+	var lines []string
+	for _, link := range task.Links {
+		lines = append(lines, fmt.Sprintf("[%s](%s)", strings.ReplaceAll(linkText(link), "]", `\]`), link.Link))
+	}
+	return lines
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interrupted is canceled on SIGINT or SIGTERM, or when --overall-timeout
+// runs out. Requests in flight are then aborted and bulk operations don't
+// start any further calls.
+var interrupted = context.Background()
+
+// Installs the handler canceling interrupted. A second signal kills the
+// process right away.
+func handleSignals() {
+	// With SIGPIPE ignored, writing to a closed pipe fails with EPIPE, which
+	// pipeWriter turns into a quiet exit.
+	signal.Ignore(syscall.SIGPIPE)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	interrupted = ctx
+	if *overallTime > 0 {
+		var cancel context.CancelFunc
+		interrupted, cancel = context.WithTimeout(ctx, *overallTime)
+		exitHooks = append(exitHooks, cancel)
+	}
+}
+
+// Reports whether interrupted was canceled by --overall-timeout.
+func timedOut() bool {
+	return errors.Is(interrupted.Err(), context.DeadlineExceeded)
+}
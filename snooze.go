@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// Moves the due of a task later by a duration like "3d" or "2h". The
+// duration is added to the current due, at its time of day if the task has
+// one and otherwise at midnight, or to now for tasks without a due.
+func snooze(srv *tasks.Service, tasklistId, taskId, by string) {
+	if taskId == "" || by == "" {
+		usageError("Missing task id or duration")
+	}
+	o, err := parseOffset(strings.TrimPrefix(by, "+"))
+	if err != nil {
+		fatalf("Invalid snooze duration: %v", err)
+	}
+	task, err := srv.Tasks.Get(tasklistId, taskId).Do()
+	if err != nil {
+		fatalf("Retrieving task failed: %v", err)
+	}
+
+	t, notes := snoozed(task, o)
+	patch := &tasks.Task{Due: formatDue(t), Notes: notes}
+	if _, err := srv.Tasks.Patch(tasklistId, taskId, patch).Do(); err != nil {
+		fatalf("Could not snooze task: %v", err)
+	}
+	fmt.Printf("Snoozed %q until %s\n", task.Title, displayTaskDue(&tasks.Task{Due: patch.Due, Notes: notes}))
+}
+
+// Returns when the task is due after snoozing it by the offset, and its
+// notes with the due time marker set to match.
+func snoozed(task *tasks.Task, o offset) (time.Time, string) {
+	anchor := currentTime().In(location())
+	clock := dueTime(task)
+	if due, err := time.Parse(time.RFC3339, task.Due); err == nil {
+		c, _ := time.Parse("15:04", clock)
+		anchor = time.Date(due.Year(), due.Month(), due.Day(), c.Hour(), c.Minute(), 0, 0, location())
+	}
+	t := o.from(anchor)
+	notes := task.Notes
+	if clock != "" || o.subDay() {
+		notes = withDueTime(stripDueTime(notes), t.Format("15:04"))
+	}
+	return t, notes
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// sortKeys are the keys --sort accepts. Each returns the value to compare,
+// empty if the task has none.
+var sortKeys = map[string]func(*tasks.Task) string{
+	"due": func(task *tasks.Task) string {
+		if task.Due == "" {
+			return ""
+		}
+		return task.Due[:10] + " " + dueTime(task)
+	},
+	"title": func(task *tasks.Task) string { return strings.ToLower(task.Title) },
+	"status": func(task *tasks.Task) string {
+		// Pending tasks come first.
+		if task.Status == "completed" {
+			return "2"
+		}
+		return "1"
+	},
+	"updated":   func(task *tasks.Task) string { return task.Updated },
+	"completed": completedTime,
+	"position":  func(task *tasks.Task) string { return task.Position },
+}
+
+type sortKey struct {
+	value func(*tasks.Task) string
+	desc  bool
+}
+
+// Parses --sort, a comma separated list of keys, and --sort-dir, giving asc
+// or desc for each key in the same order. Keys without a direction are
+// sorted ascending.
+func parseSort() []sortKey {
+	if *sortBy == "" {
+		return nil
+	}
+	var dirs []string
+	if *sortDir != "" {
+		dirs = strings.Split(*sortDir, ",")
+	}
+	var keys []sortKey
+	for i, name := range strings.Split(*sortBy, ",") {
+		value, ok := sortKeys[strings.TrimSpace(name)]
+		if !ok {
+			usageError("Unknown sort key: %s, expected due, title, status, updated, completed or position", name)
+		}
+		key := sortKey{value: value}
+		if i < len(dirs) {
+			switch strings.TrimSpace(dirs[i]) {
+			case "asc":
+			case "desc":
+				key.desc = true
+			default:
+				usageError("Unknown sort direction: %s, expected asc or desc", dirs[i])
+			}
+		}
+		keys = append(keys, key)
+	}
+	if len(dirs) > len(keys) {
+		usageError("More sort directions than sort keys given")
+	}
+	return keys
+}
+
+// Sorts tasks by the keys, the first key deciding first. The sort is stable
+// and tasks without a value for a key always come last for that key.
+func sortTasks(items []*tasks.Task, keys []sortKey) {
+	if len(keys) == 0 {
+		return
+	}
+	slices.SortStableFunc(items, func(a, b *tasks.Task) int {
+		for _, key := range keys {
+			va, vb := key.value(a), key.value(b)
+			switch {
+			case va == vb:
+				continue
+			case va == "":
+				return 1
+			case vb == "":
+				return -1
+			}
+			if key.desc {
+				return cmp.Compare(vb, va)
+			}
+			return cmp.Compare(va, vb)
+		}
+		return 0
+	})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// reorder is a single Move call: task goes after previous, or first if
+// previous is empty, keeping its parent.
+type reorder struct {
+	task     *tasks.Task
+	previous *tasks.Task
+}
+
+// Reorders the tasks of a tasklist on the server by --sort, so that the
+// order sticks in Google Tasks as well. Subtasks are sorted among their
+// siblings. Only the tasks out of place are moved.
+func sortList(srv *tasks.Service, tasklistId, title string) {
+	keys := parseSort()
+	if len(keys) == 0 {
+		usageError("Missing sort keys, use --by or --sort")
+	}
+	items := fetchTasks(srv, tasklistId)
+	hasChildren := make(map[string]bool)
+	for _, task := range items {
+		hasChildren[task.Parent] = true
+	}
+	// The top level first, then the subtasks of each task in list order.
+	parents := []string{""}
+	for _, node := range flatten(items) {
+		if hasChildren[node.task.Id] {
+			parents = append(parents, node.task.Id)
+		}
+	}
+	var moves []reorder
+	for _, parent := range parents {
+		current := siblings(items, parent, "")
+		target := slices.Clone(current)
+		sortTasks(target, keys)
+		moves = append(moves, reorders(current, target)...)
+	}
+	if len(moves) == 0 {
+		fmt.Println("Already sorted")
+		return
+	}
+	if *dryRun {
+		for _, m := range moves {
+			if m.previous == nil {
+				fmt.Printf("Would move %s to the top\n", m.task.Title)
+			} else {
+				fmt.Printf("Would move %s after %s\n", m.task.Title, m.previous.Title)
+			}
+		}
+		return
+	}
+	if !confirmBulk(fmt.Sprintf("This rewrites the order of %s in Google Tasks, moving %d tasks. Continue?", title, len(moves)), len(moves)) {
+		return
+	}
+	for i, m := range moves {
+		call := srv.Tasks.Move(tasklistId, m.task.Id)
+		if m.task.Parent != "" {
+			call = call.Parent(m.task.Parent)
+		}
+		if m.previous != nil {
+			call = call.Previous(m.previous.Id)
+		}
+		err := withBackoff(func() error {
+			_, err := call.Do()
+			return err
+		})
+		if err != nil {
+			fatalf("Could not move %s, moved %d of %d tasks: %v", m.task.Title, i, len(moves), err)
+		}
+	}
+	fmt.Printf("Moved %d tasks\n", len(moves))
+}
+
+// Returns the moves turning the current order of siblings into the target
+// order. The tasks forming the longest run already in target order stay
+// where they are, every other task is moved after its predecessor in the
+// target order, which is in place by then.
+func reorders(current, target []*tasks.Task) []reorder {
+	index := make(map[string]int)
+	for i, task := range target {
+		index[task.Id] = i
+	}
+	stay := make(map[string]bool)
+	for _, i := range longestIncreasing(current, index) {
+		stay[current[i].Id] = true
+	}
+	var moves []reorder
+	for i, task := range target {
+		if stay[task.Id] {
+			continue
+		}
+		m := reorder{task: task}
+		if i > 0 {
+			m.previous = target[i-1]
+		}
+		moves = append(moves, m)
+	}
+	return moves
+}
+
+// Returns the positions in current of a longest subsequence whose target
+// indexes increase, using patience sorting.
+func longestIncreasing(current []*tasks.Task, index map[string]int) []int {
+	var tails []int // tails[k] is the position ending the best run of length k+1
+	prev := make([]int, len(current))
+	for i, task := range current {
+		k, _ := slices.BinarySearchFunc(tails, index[task.Id], func(t, v int) int {
+			return index[current[t].Id] - v
+		})
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	var run []int
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			run = append(run, i)
+		}
+	}
+	slices.Reverse(run)
+	return run
+}
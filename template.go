@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"text/template"
+
+	"google.golang.org/api/tasks/v1"
+)
+
+// templates caches the parsed --template-file by path, so that it's read and
+// parsed only once, also when batch lines print several times.
+var templates = make(map[string]*template.Template)
+
+// templateFuncs are available in output templates in addition to the
+// builtin functions.
+var templateFuncs = template.FuncMap{
+	"due":       displayTaskDue,
+	"completed": completedDisplay,
+	"time":      displayTime,
+}
+
+// Reads and parses the template given by --template-file.
+func outputTemplate() *template.Template {
+	if t, ok := templates[*tmplFile]; ok {
+		return t
+	}
+	b, err := os.ReadFile(*tmplFile)
+	if err != nil {
+		fatalf("Could not read template: %v", err)
+	}
+	t, err := template.New("task").Funcs(templateFuncs).Parse(string(b))
+	if err != nil {
+		fatalf("Could not parse template: %v", err)
+	}
+	templates[*tmplFile] = t
+	return t
+}
+
+// Executes the template for every task. The sub-templates "header" and
+// "footer", if defined, are executed before and after the tasks with all of
+// them as data.
+func printTemplate(items []*tasks.Task) {
+	t := outputTemplate()
+	execute := func(t *template.Template, data any) {
+		if err := t.Execute(stdout, data); err != nil {
+			fatalf("Could not execute template: %v", err)
+		}
+	}
+	if header := t.Lookup("header"); header != nil {
+		execute(header, items)
+	}
+	for _, task := range items {
+		execute(t, task)
+	}
+	if footer := t.Lookup("footer"); footer != nil {
+		execute(footer, items)
+	}
+}
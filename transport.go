@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	buildinfo "runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Returns the transport used for all requests, both for the OAuth exchange
+// and the Tasks API. Proxies are taken from HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY unless --proxy is given. Connections are kept open for reuse, as
+// bulk commands make bursts of calls to the same host.
+func newTransport() http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = http.ProxyFromEnvironment
+	t.DialContext = (&net.Dialer{
+		Timeout:   *dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	t.MaxIdleConns = 100
+	t.MaxIdleConnsPerHost = max(*concurrency, http.DefaultMaxIdleConnsPerHost)
+	t.IdleConnTimeout = 90 * time.Second
+	t.ResponseHeaderTimeout = *respTimeout
+	if *proxy != "" {
+		u, err := url.Parse(*proxy)
+		if err != nil || u.Host == "" {
+			fatalf("Invalid proxy URL: %s", *proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	var rt http.RoundTripper = &userAgentTransport{next: t, agent: userAgentHeader()}
+	if *maxInflight > 0 {
+		rt = &limitTransport{next: rt, slots: make(chan struct{}, *maxInflight)}
+	}
+	rt = &cancelTransport{next: rt}
+	if *auditLog != "" {
+		rt = newAuditTransport(rt, *auditLog)
+	}
+	if logger.Enabled(context.Background(), slog.LevelInfo) {
+		rt = &logTransport{next: rt}
+	}
+	if *apiStats {
+		stats := &statsTransport{next: rt}
+		exitHooks = append(exitHooks, func() {
+			fmt.Fprintf(os.Stderr, "%d API calls, %d KB\n", stats.calls.Load(), (stats.bytes.Load()+1023)/1024)
+		})
+		rt = stats
+	}
+	return rt
+}
+
+// statsTransport counts the requests going through it and the bytes sent
+// and received.
+type statsTransport struct {
+	next  http.RoundTripper
+	calls atomic.Int64
+	bytes atomic.Int64
+}
+
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	if req.ContentLength > 0 {
+		t.bytes.Add(req.ContentLength)
+	}
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &countingReader{ReadCloser: resp.Body, n: &t.bytes}
+	return resp, nil
+}
+
+type countingReader struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
+
+// userAgentTransport sets the User-Agent header of every request, so that
+// gtasks can be told apart in API dashboards and by proxies.
+type userAgentTransport struct {
+	next  http.RoundTripper
+	agent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.agent)
+	return t.next.RoundTrip(req)
+}
+
+// Returns --user-agent, or gtasks with the version it was built as.
+func userAgentHeader() string {
+	if *userAgent != "" {
+		return *userAgent
+	}
+	version := "devel"
+	if info, ok := buildinfo.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		version = info.Main.Version
+	}
+	return "gtasks/" + version
+}
+
+// limitTransport caps the number of requests in flight at --max-inflight,
+// whichever part of gtasks makes them, so that bulk commands running several
+// pools of workers stay within the API quota together. A request holds its
+// slot until its response body is closed.
+type limitTransport struct {
+	next  http.RoundTripper
+	slots chan struct{}
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case t.slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { <-t.slots }) }
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, done: release}
+	return resp, nil
+}
+
+// cancelTransport aborts requests once interrupted is canceled. The API
+// calls don't get a context of their own, so it is added here.
+type cancelTransport struct {
+	next http.RoundTripper
+}
+
+func (t *cancelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := interrupted.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(req.Context())
+	stop := context.AfterFunc(interrupted, cancel)
+	done := func() {
+		stop()
+		cancel()
+	}
+	resp, err := t.next.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		done()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, done: done}
+	return resp, nil
+}
+
+// cancelBody releases the context of a request, or its slot of
+// limitTransport, once its response body has been read.
+type cancelBody struct {
+	io.ReadCloser
+	done func()
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.done()
+	return err
+}
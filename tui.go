@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+	"google.golang.org/api/tasks/v1"
+)
+
+const tuiHelp = "↑/↓ move  ←/→ switch list  space check/uncheck  d delete  r reload  q quit"
+
+// tui is the state of the interactive terminal UI.
+type tui struct {
+	srv       *tasks.Service
+	tasklists []*tasks.TaskList
+	list      int
+	nodes     []treeNode
+	cursor    int
+	offset    int
+	status    string
+	deleting  bool
+}
+
+// Runs an interactive terminal UI for browsing the tasks of all tasklists,
+// checking, unchecking and deleting them. It starts on the given tasklist,
+// or the first one.
+func runTui(srv *tasks.Service, tasklists []*tasks.TaskList, tasklistId string) {
+	if len(tasklists) == 0 {
+		fatalf("There are no tasklists")
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		fatalf("The tui command needs a terminal")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		fatalf("Could not set up terminal: %v", err)
+	}
+	restore := func() {
+		term.Restore(fd, state)
+		fmt.Print("\x1b[?25h\x1b[2J\x1b[H")
+	}
+	exitHooks = append(exitHooks, restore)
+	defer restore()
+	fmt.Print("\x1b[?25l")
+
+	t := &tui{srv: srv, tasklists: tasklists}
+	for i, tasklist := range tasklists {
+		if tasklist.Id == tasklistId {
+			t.list = i
+		}
+	}
+	t.load()
+	buf := make([]byte, 8)
+	for {
+		t.draw()
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			return
+		}
+		if !t.handle(string(buf[:n])) {
+			return
+		}
+	}
+}
+
+func (t *tui) load() {
+	items, err := allTasks(t.srv.Tasks.List(t.tasklists[t.list].Id).ShowHidden(true))
+	if err != nil {
+		t.status = fmt.Sprintf("Could not list tasklist items: %v", err)
+		return
+	}
+	t.nodes = flatten(items)
+	t.cursor = min(t.cursor, max(len(t.nodes)-1, 0))
+}
+
+// Handles a key press and reports whether the UI should keep running.
+func (t *tui) handle(key string) bool {
+	if t.deleting {
+		t.deleting = false
+		t.status = ""
+		if key == "y" && len(t.nodes) > 0 {
+			task := t.nodes[t.cursor].task
+			if err := t.srv.Tasks.Delete(t.tasklists[t.list].Id, task.Id).Do(); err != nil {
+				t.status = fmt.Sprintf("Could not delete task: %v", err)
+			}
+			t.load()
+		}
+		return true
+	}
+	t.status = ""
+	switch key {
+	case "q", "\x03", "\x1b":
+		return false
+	case "\x1b[A", "k":
+		t.cursor = max(t.cursor-1, 0)
+	case "\x1b[B", "j":
+		t.cursor = min(t.cursor+1, max(len(t.nodes)-1, 0))
+	case "\x1b[D", "h":
+		t.list = (t.list + len(t.tasklists) - 1) % len(t.tasklists)
+		t.cursor, t.offset = 0, 0
+		t.load()
+	case "\x1b[C", "l", "\t":
+		t.list = (t.list + 1) % len(t.tasklists)
+		t.cursor, t.offset = 0, 0
+		t.load()
+	case "r":
+		t.load()
+	case " ":
+		if len(t.nodes) == 0 {
+			break
+		}
+		task := t.nodes[t.cursor].task
+		patch := &tasks.Task{Status: "completed"}
+		if task.Status == "completed" {
+			patch = reopenPatch()
+		}
+		if _, err := t.srv.Tasks.Patch(t.tasklists[t.list].Id, task.Id, patch).Do(); err != nil {
+			t.status = fmt.Sprintf("Update task failed: %v", err)
+		}
+		t.load()
+	case "d":
+		if len(t.nodes) > 0 {
+			t.deleting = true
+			t.status = fmt.Sprintf("Delete %q? [y/N]", t.nodes[t.cursor].task.Title)
+		}
+	}
+	return true
+}
+
+func (t *tui) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+	rows := max(height-4, 1)
+	if t.cursor < t.offset {
+		t.offset = t.cursor
+	}
+	if t.cursor >= t.offset+rows {
+		t.offset = t.cursor - rows + 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&sb, "\x1b[1m%s\x1b[0m (%d/%d)\r\n\r\n", t.tasklists[t.list].Title, t.list+1, len(t.tasklists))
+	if len(t.nodes) == 0 {
+		sb.WriteString("  No tasks\r\n")
+	}
+	for i := t.offset; i < len(t.nodes) && i < t.offset+rows; i++ {
+		node := t.nodes[i]
+		line := fmt.Sprintf("%s%s %s", strings.Repeat("  ", node.depth), statusSymbol(node.task), node.task.Title)
+		line = truncate(line, width-2)
+		if i == t.cursor {
+			fmt.Fprintf(&sb, "\x1b[7m> %s\x1b[0m\r\n", line)
+		} else {
+			fmt.Fprintf(&sb, "  %s\r\n", line)
+		}
+	}
+	fmt.Fprintf(&sb, "\x1b[%d;1H", height)
+	if t.status != "" {
+		sb.WriteString(truncate(t.status, width))
+	} else {
+		sb.WriteString(truncate(tuiHelp, width))
+	}
+	os.Stdout.WriteString(sb.String())
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// emailScope is asked for in addition to the Tasks scope so that whoami can
+// tell which account a token belongs to.
+const emailScope = "https://www.googleapis.com/auth/userinfo.email"
+
+const userinfoURL = "https://www.googleapis.com/oauth2/v3/userinfo"
+
+// Prints the email address of the account the token belongs to, where the
+// token comes from and whether it grants write access.
+func whoami(client *http.Client, granted string) {
+	source := filepath.Join(getConfigDir(), "token.json")
+	if os.Getenv("GTASKS_TOKEN") != "" {
+		source = "GTASKS_TOKEN"
+	}
+	access := "read and write"
+	if isReadOnly(granted) {
+		access = "read only"
+	}
+	fmt.Printf("Account: %s\nToken:   %s\nAccess:  %s\n", accountEmail(client, granted), source, access)
+}
+
+// Prints the access token, refreshed if it expired, for use with other
+// tools like curl. Anyone with it can act as the account until it expires,
+// so it takes --i-understand, and a warning goes to stderr unless --quiet is
+// given. The refresh token is never printed.
+func printToken(client *http.Client) {
+	if !*accessToken {
+		usageError("print-token only prints the access token, give --access")
+	}
+	if !*understood {
+		usageError("The access token gives access to your tasks, give --i-understand to print it")
+	}
+	transport, ok := client.Transport.(*oauth2.Transport)
+	if !ok {
+		fatalf("Could not get the access token")
+	}
+	tok, err := transport.Source.Token()
+	if err != nil {
+		fatalf("Could not refresh the access token: %v", err)
+	}
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "Warning: this token gives access to your tasks until %s. "+
+			"Don't share it, log it or store it anywhere.\n", displayTime(tok.Expiry.Format(time.RFC3339)))
+	}
+	fmt.Println(tok.AccessToken)
+}
+
+// Returns the email address of the account the token belongs to.
+func accountEmail(client *http.Client, granted string) string {
+	source := filepath.Join(getConfigDir(), "token.json")
+	if os.Getenv("GTASKS_TOKEN") != "" {
+		source = "GTASKS_TOKEN"
+	}
+	reauthorize := fmt.Sprintf("The token doesn't tell the account. Delete %s and run gtasks again to re-authorize.", source)
+	if granted != "" && !slices.Contains(strings.Fields(granted), emailScope) {
+		fatalf("%s", reauthorize)
+	}
+
+	resp, err := client.Get(userinfoURL)
+	if err != nil {
+		fatalf("Could not retrieve account: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		fatalf("%s", reauthorize)
+	}
+	if resp.StatusCode != http.StatusOK {
+		fatalf("Could not retrieve account: %s", resp.Status)
+	}
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		fatalf("Could not parse account: %v", err)
+	}
+	return info.Email
+}
+
+// Returns the account changes may be made to, given by --require-account or
+// the GTASKS_REQUIRE_ACCOUNT environment variable, or an empty string if
+// any account will do.
+func requiredAccount() string {
+	if *requireAcct != "" {
+		return *requireAcct
+	}
+	return os.Getenv("GTASKS_REQUIRE_ACCOUNT")
+}
+
+// Exits unless the token belongs to the account required by
+// --require-account, so that a script handed the wrong token doesn't change
+// someone else's tasks.
+func checkAccount(client *http.Client, granted string) {
+	want := requiredAccount()
+	if want == "" {
+		return
+	}
+	if email := accountEmail(client, granted); !strings.EqualFold(email, want) {
+		fatalf("The token belongs to %s, but changes may only be made to %s", email, want)
+	}
+}